@@ -0,0 +1,662 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	pathpkg "path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// SUBCOMMANDS
+// ---------------------------------------------
+
+// subcommands maps each CLI verb to its runner.
+var subcommands = map[string]func(cfg Config, args []string){
+	"index":  runIndex,
+	"search": runSearch,
+	"count":  runCount,
+	"prune":  runPrune,
+	"verify": runVerify,
+	"export": runExport,
+	"import": runImport,
+	"merge":  runMerge,
+	"diff":   runDiff,
+	"stats":  runStats,
+	"watch":  runWatch,
+}
+
+func runIndex(cfg Config, args []string) {
+	fset := newFlagSet("index", "[flags] [root ...]")
+	name := addNameFlag(fset)
+	indexOpts := addIndexFlags(fset, cfg)
+	dryRun := fset.Bool("dry-run", false, "walk and summarize what would be indexed without saving")
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+	opts := indexOpts()
+
+	roots := rootsOrDefault(cfg, fset.Args(), &opts)
+	// Ctrl+C stops the walk and saves what it found; a second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+	if *dryRun {
+		idx, err := walkIndex(ctx, indexPath, roots, opts)
+		if err != nil {
+			log.Fatalf("Failed to walk roots: %v", err)
+		}
+		if err := writeDryRun(os.Stdout, idx); err != nil {
+			log.Fatalf("Failed to summarize: %v", err)
+		}
+		return
+	}
+	err := buildIndex(ctx, indexPath, roots, opts)
+	if errors.Is(err, errPartialIndex) {
+		fmt.Fprintf(os.Stderr, "Index build %v.\n", err)
+		os.Exit(130)
+	}
+	if err != nil {
+		log.Fatalf("Failed to build index: %v", err)
+	}
+}
+
+// rootsOrDefault returns the roots given on the command line, or else the
+// default ones, adding the roots file's exclude patterns to opts.
+func rootsOrDefault(cfg Config, args []string, opts *indexer.Options) []string {
+	if len(args) > 0 {
+		return args
+	}
+	roots, excludes, err := cfg.defaultRoots()
+	if err != nil {
+		log.Fatalf("Cannot read roots file: %v", err)
+	}
+	opts.Excludes = append(opts.Excludes, excludes...)
+	return roots
+}
+
+// runSearch prints the paths matching a query one per line, exiting with
+// status 1 when nothing matches so it composes with shell conditionals.
+func runSearch(cfg Config, args []string) {
+	fset := newFlagSet("search", "[flags] <query>")
+	limit := fset.Int("limit", cfg.MaxResults, "print at most `n` matches (0 = no limit)")
+	print0 := fset.Bool("print0", false, "end each path with a NUL byte instead of a newline, for xargs -0")
+	prepare := addQueryFlags(fset, cfg)
+	_ = fset.Parse(args)
+	idx, query, opts := prepare()
+	opts.Limit = *limit
+
+	matches, _ := indexer.Search(idx.Entries, query, opts)
+	if len(matches) == 0 {
+		os.Exit(1)
+	}
+	sep := "\n"
+	if *print0 {
+		sep = "\x00"
+	}
+	for _, i := range matches {
+		fmt.Print(idx.Entries[i].Path + sep)
+	}
+}
+
+// runCount prints how many files match a query, with no result cap.
+func runCount(cfg Config, args []string) {
+	fset := newFlagSet("count", "[flags] <query>")
+	prepare := addQueryFlags(fset, cfg)
+	_ = fset.Parse(args)
+	idx, query, opts := prepare()
+
+	_, total := indexer.Search(idx.Entries, query, opts)
+	fmt.Println(total)
+	if total == 0 {
+		os.Exit(1)
+	}
+}
+
+// addQueryFlags registers the flags shared by the commands that run a
+// query. The returned function, called after parsing, loads the index and
+// returns it with the query and its options, exiting on any error.
+func addQueryFlags(fset *flag.FlagSet, cfg Config) func() (*indexer.Index, string, indexer.SearchOptions) {
+	name := addNameFlag(fset)
+	modeName := fset.String("mode", cfg.SearchMode, "search `mode`: substring, fuzzy, regex or acronym")
+	caseSensitive := fset.Bool("case-sensitive", cfg.CaseSensitive, "distinguish upper and lower case")
+	smartCase := fset.Bool("smart-case", cfg.SmartCase, "distinguish case when a search term has an upper-case letter")
+	sortName := fset.String("sort", cfg.Sort, "`order` of the matches: relevance, newest or path")
+	exts := new(stringList)
+	fset.Var(exts, "ext", "only match files with this `extension` (repeatable)")
+
+	return func() (*indexer.Index, string, indexer.SearchOptions) {
+		indexPath := mustIndexPath(*name)
+		mode, err := indexer.ParseMode(*modeName)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		order, err := indexer.ParseSortOrder(*sortName)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		// --ext is shorthand for ext: terms so both forms behave identically
+		terms := fset.Args()
+		for _, ext := range *exts {
+			terms = append(terms, "ext:"+ext)
+		}
+		query := strings.Join(terms, " ")
+		if strings.TrimSpace(query) == "" {
+			fset.Usage()
+			os.Exit(2)
+		}
+
+		idx, err := indexer.Load(indexPath)
+		if err != nil {
+			log.Fatalf("Failed to load index: %v", err)
+		}
+
+		opts := indexer.SearchOptions{Mode: mode, CaseSensitive: *caseSensitive || *smartCase && indexer.SmartCase(query, mode), Sort: order, Roots: idx.Roots}
+		if mode == indexer.ModeRegex {
+			if opts.Regexp, err = indexer.CompileRegexp(query, opts.CaseSensitive); err != nil {
+				log.Fatalf("Invalid regex: %v", err)
+			}
+		}
+		return idx, query, opts
+	}
+}
+
+// runPrune drops entries whose files no longer exist and rewrites the index.
+func runPrune(cfg Config, args []string) {
+	fset := newFlagSet("prune", "")
+	name := addNameFlag(fset)
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+
+	idx, err := indexer.Load(indexPath)
+	if err != nil {
+		log.Fatalf("Failed to load index: %v", err)
+	}
+
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if _, err := os.Lstat(e.Path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	pruned := len(idx.Entries) - len(kept)
+	idx.Entries = kept
+
+	if pruned > 0 {
+		if err := indexer.Save(indexPath, idx); err != nil {
+			log.Fatalf("Failed to save index: %v", err)
+		}
+	}
+	fmt.Printf("Pruned %d missing entries, %d remain.\n", pruned, len(kept))
+}
+
+// runVerify checks the index against the file system without changing it,
+// exiting with status 1 when anything needs attention.
+func runVerify(cfg Config, args []string) {
+	fset := newFlagSet("verify", "")
+	name := addNameFlag(fset)
+	applyLogFlags := addLogFlags(fset)
+	_ = fset.Parse(args)
+	applyLogFlags()
+	indexPath := mustIndexPath(*name)
+
+	report := verifyIndex(indexPath)
+	if err := writeVerify(os.Stdout, report, verbose()); err != nil {
+		log.Fatalf("Failed to print report: %v", err)
+	}
+	if !report.ok() {
+		os.Exit(1)
+	}
+}
+
+// runExport writes the index as JSON to the file named by args.
+func runExport(cfg Config, args []string) {
+	fset := newFlagSet("export", "<file.json|->")
+	name := addNameFlag(fset)
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+	if fset.NArg() != 1 {
+		fset.Usage()
+		os.Exit(2)
+	}
+
+	idx, err := indexer.Load(indexPath)
+	if err != nil {
+		log.Fatalf("Failed to load index: %v", err)
+	}
+	if err := writeExport(fset.Arg(0), idx); err != nil {
+		log.Fatalf("Failed to export index: %v", err)
+	}
+}
+
+// runImport replaces the index with the entries of a JSON export.
+func runImport(cfg Config, args []string) {
+	fset := newFlagSet("import", "<file.json|->")
+	name := addNameFlag(fset)
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+	if fset.NArg() != 1 {
+		fset.Usage()
+		os.Exit(2)
+	}
+
+	idx, err := readExport(fset.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to import index: %v", err)
+	}
+	if err := indexer.Save(indexPath, idx); err != nil {
+		log.Fatalf("Failed to save index: %v", err)
+	}
+	fmt.Printf("Imported %d entries.\n", len(idx.Entries))
+}
+
+// runMerge unions the JSON exports named by args into one export, such as
+// the indexes of several machines, keeping the newest entry of a path.
+func runMerge(cfg Config, args []string) {
+	fset := newFlagSet("merge", "[-o merged.json] <file.json|-> ...")
+	output := fset.String("o", "-", "write the merged export to `file` (- for stdout)")
+	_ = fset.Parse(args)
+	// Flags may also follow the files, as in `merge a.json b.json -o c.json`
+	var inputs []string
+	for fset.NArg() > 0 {
+		inputs = append(inputs, fset.Arg(0))
+		_ = fset.Parse(fset.Args()[1:])
+	}
+	if len(inputs) == 0 {
+		fset.Usage()
+		os.Exit(2)
+	}
+
+	idxs := make([]*indexer.Index, len(inputs))
+	entries := 0
+	for i, path := range inputs {
+		idx, err := readExport(path)
+		if err != nil {
+			log.Fatalf("Failed to read export: %v", err)
+		}
+		idxs[i] = idx
+		entries += len(idx.Entries)
+	}
+	merged := mergeExports(idxs)
+	if err := writeExport(*output, merged); err != nil {
+		log.Fatalf("Failed to write merged export: %v", err)
+	}
+	if *output != "-" {
+		fmt.Printf("Merged %d entries from %d exports into %d.\n", entries, len(inputs), len(merged.Entries))
+	}
+}
+
+// runDiff prints the paths added and removed between two JSON exports,
+// between an export and the index, or, given no files, between the index
+// and a fresh scan of its roots. Like diff(1) it exits with status 1 when
+// they differ.
+func runDiff(cfg Config, args []string) {
+	fset := newFlagSet("diff", "[flags] [old.json [new.json]]")
+	name := addNameFlag(fset)
+	indexOpts := addIndexFlags(fset, cfg)
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+	opts := indexOpts()
+
+	var older, newer *indexer.Index
+	var err error
+	switch fset.NArg() {
+	case 0:
+		if older, err = indexer.Load(indexPath); err != nil {
+			log.Fatalf("Failed to load index: %v", err)
+		}
+		set := make(map[string]bool)
+		fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		opts = rescanOptions(older, opts, set)
+		// Progress would be mixed into the listing
+		if !verbose() {
+			logLevel.Set(slog.LevelWarn)
+		}
+		if newer, err = walkIndex(context.Background(), indexPath, older.Roots, opts); err != nil {
+			log.Fatalf("Failed to scan roots: %v", err)
+		}
+	case 1:
+		if older, err = readExport(fset.Arg(0)); err != nil {
+			log.Fatalf("Failed to read export: %v", err)
+		}
+		if newer, err = indexer.Load(indexPath); err != nil {
+			log.Fatalf("Failed to load index: %v", err)
+		}
+	case 2:
+		if older, err = readExport(fset.Arg(0)); err != nil {
+			log.Fatalf("Failed to read export: %v", err)
+		}
+		if newer, err = readExport(fset.Arg(1)); err != nil {
+			log.Fatalf("Failed to read export: %v", err)
+		}
+	default:
+		fset.Usage()
+		os.Exit(2)
+	}
+
+	d := diffIndexes(older, newer)
+	if err := writeDiff(os.Stdout, d); err != nil {
+		log.Fatalf("Failed to print diff: %v", err)
+	}
+	if !d.empty() {
+		os.Exit(1)
+	}
+}
+
+// runWatch builds the index and keeps it updated as files change.
+func runWatch(cfg Config, args []string) {
+	fset := newFlagSet("watch", "[flags] [root ...]")
+	name := addNameFlag(fset)
+	indexOpts := addIndexFlags(fset, cfg)
+	flushEvery := fset.Duration("flush-interval", indexer.DefaultFlushInterval, "how often to save the index when it has changed")
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+	opts := indexOpts()
+
+	roots := rootsOrDefault(cfg, fset.Args(), &opts)
+	if *flushEvery <= 0 {
+		log.Fatalf("--flush-interval must be positive")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := indexer.Watch(ctx, indexPath, roots, buildOptions(indexPath, opts), *flushEvery); err != nil {
+		log.Fatalf("Watch failed: %v", err)
+	}
+}
+
+// runStats prints a summary of what the index covers.
+func runStats(cfg Config, args []string) {
+	fset := newFlagSet("stats", "")
+	name := addNameFlag(fset)
+	_ = fset.Parse(args)
+	indexPath := mustIndexPath(*name)
+
+	idx, err := indexer.Load(indexPath)
+	if err != nil {
+		log.Fatalf("Failed to load index: %v", err)
+	}
+	if err := writeStats(os.Stdout, computeStats(idx)); err != nil {
+		log.Fatalf("Failed to print stats: %v", err)
+	}
+}
+
+// parseUIFlags merges the flags of a plain interactive run over the config.
+func parseUIFlags(cfg Config, args []string) (uiOptions, error) {
+	fset := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	name := fset.String("name", "", "use the index called `name`, or search several given as a comma-separated list")
+	all := fset.Bool("all", false, "search every index together")
+	modeName := fset.String("mode", cfg.SearchMode, "initial search `mode`: substring, fuzzy, regex or acronym")
+	caseSensitive := fset.Bool("case-sensitive", cfg.CaseSensitive, "start with case-sensitive matching")
+	smartCase := fset.Bool("smart-case", cfg.SmartCase, "match case-sensitively whenever a search term has an upper-case letter")
+	sortName := fset.String("sort", cfg.Sort, "initial `order` of the results: relevance, newest or path")
+	windowSize := fset.Int("window-size", cfg.WindowSize, "maximum result `rows` shown (0 = fill the terminal)")
+	maxResults := fset.Int("max-results", cfg.MaxResults, "stop collecting matches after `n` (0 = no limit)")
+	vim := fset.Bool("vim", cfg.VimMode, "use vim-style modal keys (Esc for normal mode, i to type)")
+	fileManager := fset.String("file-manager", cfg.FileManager, "`command` revealing a file, with {} for its path (default: detect)")
+	fresh := fset.Bool("fresh", false, "start with an empty query instead of the previous session's")
+	printOnly := fset.Bool("print-only", false, "print the selected path instead of opening or revealing it")
+	preview := fset.Bool("preview", cfg.Preview, "show the head of the selected file (toggle with Alt+P)")
+	maxAge := fset.Duration("max-age", cfg.maxIndexAge(), "rebuild the index in the background for next time once it is older than `age`")
+	noAutoReindex := fset.Bool("no-auto-reindex", false, "never rebuild a stale index in the background")
+	applyLogFlags := addLogFlags(fset)
+	fset.Usage = func() {
+		verbs := slices.Sorted(maps.Keys(subcommands))
+		prog := fset.Name()
+		fmt.Fprintf(fset.Output(), "Usage: %s [flags]\n       %s %s [flags] ...\n", prog, prog, strings.Join(verbs, "|"))
+		fset.PrintDefaults()
+	}
+	_ = fset.Parse(args)
+	applyLogFlags()
+
+	if fset.NArg() > 0 {
+		return uiOptions{}, fmt.Errorf("unknown command %q", fset.Arg(0))
+	}
+	mode, err := indexer.ParseMode(*modeName)
+	if err != nil {
+		return uiOptions{}, err
+	}
+	order, err := indexer.ParseSortOrder(*sortName)
+	if err != nil {
+		return uiOptions{}, err
+	}
+	theme, err := loadTheme(cfg.Theme, cfg.Colors)
+	if err != nil {
+		return uiOptions{}, err
+	}
+	typeTags, err := parseTypeTags(cfg.TypeTags)
+	if err != nil {
+		return uiOptions{}, err
+	}
+	if *noAutoReindex {
+		*maxAge = 0
+	}
+	return uiOptions{
+		Names:         splitNames(*name),
+		All:           *all,
+		Vim:           *vim,
+		Preview:       *preview,
+		FileManager:   *fileManager,
+		Fresh:         *fresh,
+		PrintOnly:     *printOnly,
+		MaxAge:        max(*maxAge, 0),
+		MinTermLength: cfg.MinTermLength,
+		ListAll:       cfg.ListAll,
+		RecentFiles:   cfg.RecentFiles,
+		Theme:         theme,
+		Footer:        cfg.Footer,
+		TypeTags:      typeTags,
+		Mode:          mode,
+		Sort:          order,
+		CaseSensitive: *caseSensitive,
+		SmartCase:     *smartCase,
+		WindowSize:    *windowSize,
+		MaxResults:    *maxResults,
+	}, nil
+}
+
+// addIndexFlags registers the flags that control what gets indexed. The
+// returned function yields the resulting options once fset is parsed.
+func addIndexFlags(fset *flag.FlagSet, cfg Config) func() indexer.Options {
+	opts := cfg.indexOptions()
+	excludes := newPatternList(opts.Excludes)
+	fset.Var(excludes, "exclude", "glob `pattern` of directories to skip (repeatable, replaces the defaults)")
+	fset.BoolVar(&opts.UseGitignore, "use-gitignore", false, "skip files and directories matched by .gitignore files")
+	fset.BoolVar(&opts.Incremental, "incremental", false, "reuse entries from the existing index for unchanged directories")
+	var includeExts stringList
+	fset.Var(&includeExts, "include-ext", "only index files with this `extension` (repeatable)")
+	fset.Var(sinceFlag{&opts.Since}, "since", "only index files modified within this `age` (e.g. 7d, 12h, 1d12h) or since an RFC 3339 time or YYYY-MM-DD date")
+	fset.Var(sizeFlag{&opts.MaxFileSize}, "max-file-size", "skip files larger than `size` (e.g. 500M, 2G)")
+	fset.BoolVar(&opts.IncludeHidden, "include-hidden", false, "also index hidden directories such as .config")
+	fset.BoolVar(&opts.InterleaveRoots, "interleave-roots", false, "list the files of several roots alternately rather than one root after another")
+	fset.BoolVar(&opts.DedupInodes, "dedup-inodes", false, "index a file reachable by several paths (links, aliased roots) only once")
+	fset.BoolVar(&opts.FollowSymlinks, "follow-symlinks", false, "follow symlinked directories and files (each target is indexed once)")
+	fset.IntVar(&opts.MaxDepth, "max-depth", opts.MaxDepth, "descend at most `n` directory levels below each root (0 = root files only, -1 = no limit)")
+	applyLogFlags := addLogFlags(fset)
+	return func() indexer.Options {
+		applyLogFlags()
+		opts.Excludes = excludes.values
+		opts.IncludeExts = indexer.NormalizeExts(includeExts)
+		opts.Verbose = verbose()
+		return opts
+	}
+}
+
+// splitNames splits a comma-separated list of index names, dropping
+// duplicates. "default" stands for the default index in a list.
+func splitNames(list string) []string {
+	if list == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == defaultIndexName && strings.Contains(list, ",") {
+			name = ""
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// addNameFlag registers the --name flag selecting a named index.
+func addNameFlag(fset *flag.FlagSet) *string {
+	return fset.String("name", "", "use the index called `name` instead of the default one")
+}
+
+// mustIndexPath resolves the index file for name or exits.
+func mustIndexPath(name string) string {
+	path, err := getIndexFilePath(name)
+	if err != nil {
+		log.Fatalf("System error: %v", err)
+	}
+	return path
+}
+
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ExitOnError)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: %s %s %s\n", filepath.Base(os.Args[0]), name, synopsis)
+		fset.PrintDefaults()
+	}
+	return fset
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// sinceFlag is a flag holding a point in time, given either as an age
+// counted back from now or as an absolute date.
+type sinceFlag struct {
+	t *time.Time
+}
+
+func (s sinceFlag) String() string {
+	if s.t == nil || s.t.IsZero() {
+		return ""
+	}
+	return s.t.Format(time.RFC3339)
+}
+
+func (s sinceFlag) Set(v string) error {
+	t, err := parseSince(v, time.Now())
+	if err != nil {
+		return err
+	}
+	*s.t = t
+	return nil
+}
+
+// sizeFlag is a flag holding a size in bytes with an optional k, M or G
+// suffix.
+type sizeFlag struct {
+	n *int64
+}
+
+func (s sizeFlag) String() string {
+	if s.n == nil || *s.n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(*s.n, 10)
+}
+
+func (s sizeFlag) Set(v string) error {
+	n, err := indexer.ParseSize(v)
+	if err != nil {
+		return err
+	}
+	*s.n = n
+	return nil
+}
+
+// parseSince parses an RFC 3339 time, a local YYYY-MM-DD date, or an age
+// such as "7d", "12h" or "1d12h" before now. Days are 24 hours long;
+// hours, minutes and seconds follow time.ParseDuration.
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+
+	var age time.Duration
+	rest := v
+	if days, after, ok := strings.Cut(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid age %q: want e.g. 7d, 12h or 2006-01-02", v)
+		}
+		age, rest = time.Duration(n)*24*time.Hour, after
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid age %q: want e.g. 7d, 12h or 2006-01-02", v)
+		}
+		age += d
+	}
+	return now.Add(-age), nil
+}
+
+// patternList is a repeatable flag of glob patterns. The first explicit
+// value replaces the defaults it was created with.
+type patternList struct {
+	values []string
+	set    bool
+}
+
+func newPatternList(defaults []string) *patternList {
+	return &patternList{values: append([]string(nil), defaults...)}
+}
+
+func (p *patternList) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(p.values, ",")
+}
+
+func (p *patternList) Set(v string) error {
+	if !p.set {
+		p.values, p.set = nil, true
+	}
+	// An empty value clears the defaults without adding a pattern
+	if v == "" {
+		return nil
+	}
+	if _, err := pathpkg.Match(v, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", v, err)
+	}
+	p.values = append(p.values, v)
+	return nil
+}
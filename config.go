@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// CONFIGURATION
+// ---------------------------------------------
+
+// Config holds the persistent settings read from config.json. Command-line
+// flags always take precedence over these values.
+type Config struct {
+	// Roots are indexed when `index` is run without explicit roots.
+	Roots []string `json:"roots"`
+	// Excludes are the default --exclude patterns.
+	Excludes []string `json:"excludes"`
+	// SearchMode is the mode the UI starts in: "substring", "fuzzy",
+	// "regex" or "acronym".
+	SearchMode string `json:"search_mode"`
+	// Sort is the order results start in: "relevance", "newest" or "path".
+	Sort string `json:"sort"`
+	// CaseSensitive makes matching distinguish upper and lower case.
+	CaseSensitive bool `json:"case_sensitive"`
+	// SmartCase matches case-sensitively anyway when a search term, rather
+	// than an operator such as size:>1M, has an upper-case letter, like
+	// smartcase in vim.
+	SmartCase bool `json:"smart_case"`
+	// WindowSize caps the number of result rows shown; 0 fills the terminal.
+	WindowSize int `json:"window_size"`
+	// MaxResults caps how many matches a search keeps; 0 means unlimited.
+	MaxResults int `json:"max_results"`
+	// VimMode enables modal vim-style navigation keys in the UI.
+	VimMode bool `json:"vim_mode"`
+	// Preview shows the head of the selected file below the results.
+	Preview bool `json:"preview"`
+	// OpenCommand, when set, is run on Enter instead of revealing the file,
+	// with "{}" replaced by its path, e.g. "code -g {}" or "vim {}".
+	OpenCommand string `json:"open_command"`
+	// MinTermLength holds off searching until a term has this many
+	// characters; 0 searches from the first keystroke.
+	MinTermLength int `json:"min_term_length"`
+	// ListAll enables Alt+L, listing the whole index when the query is empty.
+	ListAll bool `json:"list_all"`
+	// RecentFiles is how many of the most recently modified files Alt+R
+	// lists while the query is empty; 0 lists them all.
+	RecentFiles int `json:"recent_files"`
+	// MaxIndexAge is how old the index may get, e.g. "24h", before the
+	// UI rebuilds it in the background; "0" turns this off.
+	MaxIndexAge string `json:"max_index_age"`
+	// FileManager is the command that reveals a file, with "{}" replaced
+	// by its path, e.g. "nemo {}". Empty detects the platform's manager.
+	FileManager string `json:"file_manager"`
+	// Footer is the summary the UI shows below the results while there
+	// are matches, with {start} and {end} replaced by the rows shown,
+	// {total} by the number of matches, {dirs} by the directories they
+	// are in, {mode} by the active search modes, {sort} by the order,
+	// {files} by the size of the index and {selected} by the path under
+	// the cursor, e.g. "{start}-{end}/{total} {selected}".
+	Footer string `json:"footer"`
+	// TypeTags prefixes each result with the type of its file: "labels"
+	// for colored tags such as [go], "icons" for Nerd Font glyphs, which
+	// need a patched font, or "off".
+	TypeTags string `json:"type_tags"`
+	// Theme picks the UI colors: "default", "light" or "mono".
+	Theme string `json:"theme"`
+	// Colors overrides single colors of the theme with SGR codes, e.g.
+	// {"cursor": "1;35"} for a bold magenta cursor row.
+	Colors theme `json:"colors"`
+}
+
+// openCommandEnv overrides Config.OpenCommand when set.
+const openCommandEnv = "FILE_INDEXER_OPEN_COMMAND"
+
+// defaultMaxResults caps how many results a single search returns unless
+// configured otherwise, protecting against queries that match everything.
+const defaultMaxResults = 1000
+
+// defaultRecentFiles is how many files the recent view lists by default.
+const defaultRecentFiles = 50
+
+// defaultMaxIndexAge is the index age past which the UI rebuilds it.
+const defaultMaxIndexAge = 24 * time.Hour
+
+func defaultConfig() Config {
+	return Config{
+		Roots:       []string{},
+		Theme:       defaultThemeName,
+		Footer:      defaultFooter,
+		TypeTags:    "off",
+		SmartCase:   true,
+		Excludes:    indexer.DefaultOptions().Excludes,
+		SearchMode:  indexer.ModeSubstring.String(),
+		MaxResults:  defaultMaxResults,
+		RecentFiles: defaultRecentFiles,
+		MaxIndexAge: defaultMaxIndexAge.String(),
+	}
+}
+
+// indexOptions returns the indexing defaults with the configured excludes.
+func (c Config) indexOptions() indexer.Options {
+	opts := indexer.DefaultOptions()
+	opts.Excludes = c.Excludes
+	return opts
+}
+
+// maxIndexAge returns MaxIndexAge as a duration, 0 when unset. LoadConfig
+// has checked that it parses.
+func (c Config) maxIndexAge() time.Duration {
+	d, _ := time.ParseDuration(c.MaxIndexAge)
+	return d
+}
+
+// openCommand returns the command Enter runs, preferring the environment
+// over the config file, or "" to reveal the file in the file manager.
+func (c Config) openCommand() string {
+	if cmd, ok := os.LookupEnv(openCommandEnv); ok {
+		return cmd
+	}
+	return c.OpenCommand
+}
+
+func getConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find config directory: %w", err)
+	}
+	return filepath.Join(dir, "file-indexer"), nil
+}
+
+// rootsFileName is a file in the config directory listing the roots to
+// index, one per line, which takes precedence over the roots setting.
+const rootsFileName = "roots"
+
+// defaultRoots returns the roots `index` walks when given none, along with
+// the extra exclude patterns of the roots file if there is one.
+func (c Config) defaultRoots() (roots, excludes []string, err error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	roots, excludes, err = readRootsFile(filepath.Join(dir, rootsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return c.Roots, nil, nil
+	}
+	return roots, excludes, err
+}
+
+// readRootsFile parses a roots file: each line is a root, or with a
+// leading "!" an exclude pattern, and blank lines and "#" comments are
+// ignored.
+func readRootsFile(path string) (roots, excludes []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, ok := strings.CutPrefix(line, "!")
+		if !ok {
+			roots = append(roots, line)
+			continue
+		}
+		pattern = strings.TrimSpace(pattern)
+		if _, err := pathpkg.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, nil, fmt.Errorf("%s:%d: invalid exclude pattern %q", path, n+1, pattern)
+		}
+		excludes = append(excludes, pattern)
+	}
+	return roots, excludes, nil
+}
+
+// LoadConfig reads the config file, creating it with the defaults on first
+// run so there is something to edit. Keys missing from the file keep their
+// default values.
+func LoadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	dir, err := getConfigDir()
+	if err != nil {
+		return cfg, err
+	}
+	path := filepath.Join(dir, "config.json")
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, writeConfig(path, cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("cannot read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if _, err := indexer.ParseMode(cfg.SearchMode); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if _, err := indexer.ParseSortOrder(cfg.Sort); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if _, err := loadTheme(cfg.Theme, cfg.Colors); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if _, err := parseTypeTags(cfg.TypeTags); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if d, err := time.ParseDuration(cfg.MaxIndexAge); cfg.MaxIndexAge != "" && (err != nil || d < 0) {
+		return cfg, fmt.Errorf("invalid config %s: invalid max_index_age %q: want a duration such as \"24h\"", path, cfg.MaxIndexAge)
+	}
+	return cfg, nil
+}
+
+func writeConfig(path string, cfg Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("cannot create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Security: 0600 = Read/Write by owner only
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("cannot write config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// INDEX DIFF
+// ---------------------------------------------
+
+// indexDiff lists the paths that differ between two indexes, each sorted.
+type indexDiff struct {
+	added   []string // only in the newer index
+	removed []string // only in the older index
+}
+
+// diffIndexes compares the paths of older and newer.
+func diffIndexes(older, newer *indexer.Index) indexDiff {
+	before := make(map[string]bool, len(older.Entries))
+	for _, e := range older.Entries {
+		before[e.Path] = true
+	}
+	after := make(map[string]bool, len(newer.Entries))
+	for _, e := range newer.Entries {
+		after[e.Path] = true
+	}
+
+	var d indexDiff
+	for p := range after {
+		if !before[p] {
+			d.added = append(d.added, p)
+		}
+	}
+	for p := range before {
+		if !after[p] {
+			d.removed = append(d.removed, p)
+		}
+	}
+	slices.Sort(d.added)
+	slices.Sort(d.removed)
+	return d
+}
+
+// rescanOptions returns the options for scanning the roots of older again
+// to diff against it: those older was built with, so only real changes
+// show up, overridden by the index flags in set, named as on the command
+// line, which opts holds the values of. An index that doesn't record all
+// its options only supplies the ones it has.
+func rescanOptions(older *indexer.Index, opts indexer.Options, set map[string]bool) indexer.Options {
+	recorded, ok := older.BuildOptions()
+	if !ok {
+		opts.IncludeHidden = opts.IncludeHidden || older.IncludeHidden
+		if len(opts.IncludeExts) == 0 {
+			opts.IncludeExts = older.IncludeExts
+		}
+		if opts.Since.IsZero() {
+			opts.Since = older.Since
+		}
+		if opts.MaxFileSize == 0 {
+			opts.MaxFileSize = older.MaxFileSize
+		}
+		return opts
+	}
+	recorded.Incremental, recorded.Verbose = opts.Incremental, opts.Verbose
+	for name, apply := range map[string]func(){
+		"exclude":          func() { recorded.Excludes = opts.Excludes },
+		"use-gitignore":    func() { recorded.UseGitignore = opts.UseGitignore },
+		"max-depth":        func() { recorded.MaxDepth = opts.MaxDepth },
+		"follow-symlinks":  func() { recorded.FollowSymlinks = opts.FollowSymlinks },
+		"include-hidden":   func() { recorded.IncludeHidden = opts.IncludeHidden },
+		"include-ext":      func() { recorded.IncludeExts = opts.IncludeExts },
+		"since":            func() { recorded.Since = opts.Since },
+		"max-file-size":    func() { recorded.MaxFileSize = opts.MaxFileSize },
+		"interleave-roots": func() { recorded.InterleaveRoots = opts.InterleaveRoots },
+		"dedup-inodes":     func() { recorded.DedupInodes = opts.DedupInodes },
+	} {
+		if set[name] {
+			apply()
+		}
+	}
+	return recorded
+}
+
+// empty reports whether both indexes hold the same paths.
+func (d indexDiff) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0
+}
+
+// writeDiff prints the added paths, then the removed ones, then a line of
+// counts.
+func writeDiff(w io.Writer, d indexDiff) error {
+	for _, group := range []struct {
+		title string
+		mark  string
+		paths []string
+	}{
+		{"Added", "+", d.added},
+		{"Removed", "-", d.removed},
+	} {
+		if len(group.paths) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s (%d):\n", group.title, len(group.paths))
+		for _, p := range group.paths {
+			fmt.Fprintf(w, "  %s %s\n", group.mark, p)
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed\n", len(d.added), len(d.removed))
+	return err
+}
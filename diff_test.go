@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"filesearcher/indexer"
+)
+
+func TestDiffRescanKeepsExcludes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"keep/a.txt", "skip/b.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := indexer.DefaultOptions()
+	opts.Excludes = append(opts.Excludes, "skip")
+	older, err := indexer.Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		added []string
+	}{
+		{"recorded exclude", nil, nil},
+		{"unrelated flag", []string{"--include-hidden"}, nil},
+		{"exclude overridden", []string{"--exclude="}, []string{filepath.Join(root, "skip", "b.txt")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := flag.NewFlagSet("diff", flag.ContinueOnError)
+			indexOpts := addIndexFlags(fset, defaultConfig())
+			if err := fset.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			set := make(map[string]bool)
+			fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+			newer, err := indexer.Build(older.Roots, rescanOptions(older, indexOpts(), set))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := diffIndexes(older, newer)
+			if !slices.Equal(d.added, tt.added) || len(d.removed) > 0 {
+				t.Errorf("diff added %q and removed %q, want added %q", d.added, d.removed, tt.added)
+			}
+		})
+	}
+}
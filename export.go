@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// JSON EXPORT & IMPORT
+// ---------------------------------------------
+
+// indexExport is the JSON form of an index written by export and read by
+// import. Only roots and entries are carried; directory mtimes are an
+// implementation detail of incremental builds.
+type indexExport struct {
+	Roots   []string            `json:"roots"`
+	Entries []indexer.FileEntry `json:"entries"`
+}
+
+// writeExport writes idx as indented JSON with entries sorted by path, so
+// successive exports of the same tree diff cleanly. A path of "-" writes
+// to stdout.
+func writeExport(path string, idx *indexer.Index) error {
+	entries := slices.Clone(idx.Entries)
+	slices.SortFunc(entries, func(a, b indexer.FileEntry) int { return strings.Compare(a.Path, b.Path) })
+
+	out := io.Writer(os.Stdout)
+	if path != "-" {
+		// Security: 0600 = Read/Write by owner only
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("cannot create export file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(indexExport{Roots: idx.Roots, Entries: entries}); err != nil {
+		return fmt.Errorf("cannot write export: %w", err)
+	}
+	return nil
+}
+
+// readExport reads an index previously written by writeExport. A path of
+// "-" reads from stdin.
+func readExport(path string) (*indexer.Index, error) {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open export file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var exp indexExport
+	if err := json.NewDecoder(in).Decode(&exp); err != nil {
+		return nil, fmt.Errorf("invalid export %s: %w", path, err)
+	}
+	return &indexer.Index{Roots: exp.Roots, Entries: exp.Entries}, nil
+}
+
+// mergeExports unions idxs into one index. A path found in several is kept
+// once, with the entry modified last, or the first of equally new ones.
+func mergeExports(idxs []*indexer.Index) *indexer.Index {
+	merged := &indexer.Index{}
+	at := make(map[string]int) // position of each path in merged.Entries
+	for _, idx := range idxs {
+		for _, root := range idx.Roots {
+			if !slices.Contains(merged.Roots, root) {
+				merged.Roots = append(merged.Roots, root)
+			}
+		}
+		for _, e := range idx.Entries {
+			i, dup := at[e.Path]
+			if !dup {
+				at[e.Path] = len(merged.Entries)
+				merged.Entries = append(merged.Entries, e)
+			} else if e.ModTime.After(merged.Entries[i].ModTime) {
+				merged.Entries[i] = e
+			}
+		}
+	}
+	return merged
+}
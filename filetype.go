@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ---------------------------------------------
+// FILE TYPE TAGS
+// ---------------------------------------------
+
+// typeTagStyle is how each result row shows the type of its file.
+type typeTagStyle int
+
+const (
+	typeTagsOff    typeTagStyle = iota // no tags
+	typeTagsLabels                     // a colored label such as [go]
+	typeTagsIcons                      // a Nerd Font glyph, which needs a patched font
+)
+
+// parseTypeTags parses the type_tags config setting; "" turns tags off.
+func parseTypeTags(s string) (typeTagStyle, error) {
+	switch s {
+	case "", "off":
+		return typeTagsOff, nil
+	case "labels":
+		return typeTagsLabels, nil
+	case "icons":
+		return typeTagsIcons, nil
+	}
+	return typeTagsOff, fmt.Errorf("unknown type_tags %q (want off, labels or icons)", s)
+}
+
+// fileType is the tag shown for the files of one kind: a label of at most
+// typeLabelWidth characters, a Nerd Font glyph and the SGR color of both.
+type fileType struct {
+	label string
+	icon  string
+	color string
+}
+
+// typeLabelWidth is the widest label, to which shorter ones are padded so
+// the paths behind them line up.
+const typeLabelWidth = 4
+
+var (
+	imageType   = fileType{"img", "\uf1c5", "35"}
+	archiveType = fileType{"zip", "\uf1c6", "31"}
+	audioType   = fileType{"aud", "\uf1c7", "36"}
+	videoType   = fileType{"vid", "\uf1c8", "36"}
+	configType  = fileType{"cfg", "\ue615", "33"}
+	// otherType is for extensions fileTypes doesn't name
+	otherType = fileType{"", "\uf15b", ""}
+)
+
+// fileTypes maps lowercased extensions, including the dot, to their tag.
+var fileTypes = map[string]fileType{
+	".go":   {"go", "\ue627", "36"},
+	".md":   {"md", "\ue609", "34"},
+	".py":   {"py", "\ue606", "33"},
+	".js":   {"js", "\ue60c", "33"},
+	".ts":   {"ts", "\ue628", "34"},
+	".rs":   {"rs", "\ue7a8", "31"},
+	".c":    {"c", "\ue61e", "34"},
+	".h":    {"h", "\ue61e", "35"},
+	".cpp":  {"c++", "\ue61d", "34"},
+	".java": {"java", "\ue738", "31"},
+	".rb":   {"rb", "\ue739", "31"},
+	".php":  {"php", "\ue73d", "35"},
+	".lua":  {"lua", "\ue620", "34"},
+	".sh":   {"sh", "\uf489", "32"},
+	".html": {"html", "\ue60e", "31"},
+	".css":  {"css", "\ue614", "35"},
+	".json": {"json", "\ue60b", "33"},
+	".txt":  {"txt", "\uf15c", "39"},
+	".pdf":  {"pdf", "\uf1c1", "31"},
+	".yaml": configType,
+	".yml":  configType,
+	".toml": configType,
+	".ini":  configType,
+	".png":  imageType,
+	".jpg":  imageType,
+	".jpeg": imageType,
+	".gif":  imageType,
+	".svg":  imageType,
+	".webp": imageType,
+	".zip":  archiveType,
+	".gz":   archiveType,
+	".tar":  archiveType,
+	".7z":   archiveType,
+	".mp3":  audioType,
+	".flac": audioType,
+	".wav":  audioType,
+	".mp4":  videoType,
+	".mkv":  videoType,
+	".mov":  videoType,
+}
+
+// typeTag renders the tag of path in style, padded to the same width for
+// every file and followed by a space, or returns "" when tags are off.
+// Unknown extensions are labeled with themselves in dim.
+func (m model) typeTag(path string) string {
+	if m.typeTags == typeTagsOff {
+		return ""
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	t, ok := fileTypes[ext]
+	if !ok {
+		t = otherType
+		t.label, t.color = runewidth.Truncate(strings.TrimPrefix(ext, "."), typeLabelWidth, ""), m.theme.Dim
+	}
+	if m.typeTags == typeTagsIcons {
+		// Patched fonts draw the glyphs a cell wide, whatever runewidth says
+		return paint(t.color, t.icon) + " "
+	}
+	text := strings.Repeat(" ", typeLabelWidth+2)
+	if t.label != "" {
+		text = fmt.Sprintf("[%s]%s", t.label, strings.Repeat(" ", typeLabelWidth-runewidth.StringWidth(t.label)))
+	}
+	return paint(t.color, text) + " "
+}
+
+// typeTagWidth is how many cells typeTag takes on every row.
+func (m model) typeTagWidth() int {
+	switch m.typeTags {
+	case typeTagsLabels:
+		return typeLabelWidth + 3
+	case typeTagsIcons:
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// RESULT GROUPING
+// ---------------------------------------------
+
+// groupRow is one line of the grouped result list: a match, or the header
+// of the directory the matches below it are in.
+type groupRow struct {
+	match  int // position in matches; for a header its directory's best match
+	header bool
+	dir    string // the header's directory
+	count  int    // matches in the header's directory
+}
+
+// regroup rebuilds rows after the matches or the grouping changed. Each
+// directory is listed where its best match would be in the flat list, its
+// matches keeping their order below it.
+func (m *model) regroup() {
+	m.rows, m.rowOf = nil, nil
+	if !m.grouped {
+		return
+	}
+	var dirs []string
+	members := make(map[string][]int)
+	for i := range m.matches {
+		dir := filepath.Dir(m.matchPath(i))
+		if _, ok := members[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		members[dir] = append(members[dir], i)
+	}
+
+	m.rowOf = make([]int, len(m.matches))
+	for _, dir := range dirs {
+		header := len(m.rows)
+		m.rows = append(m.rows, groupRow{match: members[dir][0], header: true, dir: dir, count: len(members[dir])})
+		for _, i := range members[dir] {
+			m.rowOf[i] = header
+			if !m.collapsed[dir] {
+				m.rowOf[i] = len(m.rows)
+				m.rows = append(m.rows, groupRow{match: i})
+			}
+		}
+	}
+}
+
+// rowCount is the number of lines the result list takes.
+func (m model) rowCount() int {
+	if m.grouped {
+		return len(m.rows)
+	}
+	return len(m.matches)
+}
+
+// rowMatch returns the match the cursor selects on row r. ok is false for
+// rows it can't rest on: the headers of expanded directories.
+func (m model) rowMatch(r int) (int, bool) {
+	if r < 0 || r >= m.rowCount() {
+		return 0, false
+	}
+	if !m.grouped {
+		return r, true
+	}
+	row := m.rows[r]
+	return row.match, !row.header || m.collapsed[row.dir]
+}
+
+// matchRow returns the row match i is drawn on, the header's when its
+// directory is collapsed.
+func (m model) matchRow(i int) int {
+	if !m.grouped || i < 0 || i >= len(m.rowOf) {
+		return i
+	}
+	return m.rowOf[i]
+}
+
+// onCollapsedHeader reports whether the cursor rests on the header of a
+// collapsed directory.
+func (m model) onCollapsedHeader() bool {
+	r := m.matchRow(m.cursor)
+	return m.grouped && r >= 0 && r < len(m.rows) && m.rows[r].header
+}
+
+// toggleGroups switches between the flat and the grouped list, keeping
+// the cursor on the same match.
+func (m *model) toggleGroups() {
+	m.grouped = !m.grouped
+	m.collapsed = make(map[string]bool)
+	m.regroup()
+	m.windowStart = 0
+	m.setCursor(m.cursor)
+}
+
+// toggleCollapsed collapses the directory of the match under the cursor
+// to its header line, or expands it again.
+func (m *model) toggleCollapsed() {
+	if !m.grouped || m.cursor >= len(m.matches) {
+		return
+	}
+	dir := filepath.Dir(m.matchPath(m.cursor))
+	m.collapsed[dir] = !m.collapsed[dir]
+	m.regroup()
+	// The header stands for the directory's best match
+	m.setCursor(m.rows[m.matchRow(m.cursor)].match)
+}
+
+// groupCount is the number of directories the matches are in.
+func (m model) groupCount() int {
+	n := 0
+	for _, row := range m.rows {
+		if row.header {
+			n++
+		}
+	}
+	return n
+}
+
+// groupHeader renders the header line of a directory in style, with a
+// marker showing whether it is collapsed and its number of matches.
+func (m model) groupHeader(row groupRow, style string) string {
+	marker := "\u25be "
+	if m.collapsed[row.dir] {
+		marker = "\u25b8 "
+	}
+	count := fmt.Sprintf(" (%d)", row.count)
+	dir := row.dir
+	if m.width > 0 {
+		dir, _ = fitPath(dir, nil, m.width-2-runewidth.StringWidth(marker+count))
+	}
+	return highlight(marker+dir, nil, style, "") + paint(m.theme.Dim, count)
+}
+
+// baseName cuts path down to its base name, moving ranges, byte ranges of
+// path, along and dropping those in the directory part.
+func baseName(path string, ranges []indexer.Range) (string, []indexer.Range) {
+	start := strings.LastIndexAny(path, `/\`) + 1
+	var moved []indexer.Range
+	for _, r := range ranges {
+		if r.End > start {
+			moved = append(moved, indexer.Range{Start: max(r.Start, start) - start, End: r.End - start})
+		}
+	}
+	return path[start:], moved
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------
+// HELP OVERLAY
+// ---------------------------------------------
+
+// keyHelp is one line of the help overlay.
+type keyHelp struct {
+	keys string
+	desc string
+}
+
+var helpSections = []struct {
+	title    string
+	bindings []keyHelp
+}{
+	{"Navigation", []keyHelp{
+		{"Up / Down", "move the cursor"},
+		{"PgUp / PgDn", "scroll a page"},
+		{"Home / End", "jump to the first or last match"},
+		{"Ctrl+G", "type a result number, then Enter to jump to it"},
+		{"Ctrl+P / Ctrl+N", "recall the previous or next query from history"},
+		{"Alt+L", "list the whole index while the query is empty (if list_all is set)"},
+		{"Alt+R", "list the most recently modified files while the query is empty"},
+		{"Mouse wheel", "scroll the results"},
+	}},
+	{"Editing", []keyHelp{
+		{"Left / Right", "move the caret within the query"},
+		{"Ctrl+A / Ctrl+E", "move the caret to the start or end of the query"},
+		{"Backspace / Delete", "delete the character before or after the caret"},
+		{"Ctrl+W", "delete the word before the caret"},
+		{"Ctrl+V", "paste the clipboard at the caret"},
+		{"Tab", "complete the last term with the text every match continues it with"},
+	}},
+	{"Selection", []keyHelp{
+		{"Enter", "reveal the file in the file manager, or run open_command"},
+		{"Ctrl+O", "open the file in its default application"},
+		{"Ctrl+L", "quit and print the file's directory, e.g. for cd \"$(file-indexer)\""},
+		{"Alt+Y", "copy the paths of all matches, one per line"},
+		{"Click", "move the cursor; double-click opens like Ctrl+O"},
+	}},
+	{"Modes", []keyHelp{
+		{"Ctrl+F", "toggle fuzzy matching"},
+		{"Ctrl+R", "toggle regex matching"},
+		{"Alt+A", "toggle acronym matching (hc finds HttpClient.go)"},
+		{"Alt+C", "toggle case-sensitive matching (with smart_case, an upper-case letter turns it on too)"},
+		{"Alt+N", "toggle matching file names only"},
+		{"Alt+T", "cycle the order: relevance, newest first, path"},
+		{"Alt+S", "cycle between searching one root at a time and all of them"},
+		{"Alt+P", "toggle the file preview"},
+		{"Alt+G", "toggle grouping the matches by directory"},
+		{"Alt+Z", "collapse or expand the directory under the cursor (when grouped)"},
+	}},
+	{"Query operators", []keyHelp{
+		{"\"new folder\"", "match the quoted text, spaces included, as one term"},
+		{"-term", "exclude paths containing term"},
+		{"ext:go", "only files with the extension (repeat to allow several)"},
+		{"dir:src", "only files whose directory contains src"},
+		{"name:main", "only files whose name contains main"},
+		{"=main.go", "only files named exactly main.go"},
+		{"^src/", "only paths starting with src/ below their root"},
+		{"size:>10M", "only files larger than 10 MiB (or size:<1k; k, M, G)"},
+		{"content:todo", "only text files (up to 4 MiB) containing todo; slower"},
+	}},
+	{"General", []keyHelp{
+		{"?", "show or hide this help (when the query is empty)"},
+		{"Esc / Ctrl+C", "cancel: quit without opening anything; Esc first stops a search still running"},
+	}},
+}
+
+var vimHelp = []keyHelp{
+	{"Esc", "leave typing for normal mode (Esc again cancels)"},
+	{"i or /", "return to typing"},
+	{"j / k", "move the cursor"},
+	{"g / G", "jump to the first or last match"},
+	{"Ctrl+D / Ctrl+U", "scroll half a page"},
+	{"q", "cancel: quit without opening anything"},
+}
+
+// handleHelpKey opens and closes the help overlay, reporting whether it
+// consumed msg. While help is shown every key is consumed so nothing is
+// typed behind it. "?" only opens help when it can't be meant as query
+// text: with an empty query or in vim normal mode.
+func (m *model) handleHelpKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	isHelpKey := msg.Type == tea.KeyRunes && !msg.Alt && msg.String() == "?"
+	if m.showHelp {
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			return m.quit(), true
+		case msg.Type == tea.KeyEsc, isHelpKey:
+			m.showHelp = false
+		}
+		return nil, true
+	}
+	if isHelpKey && (m.query == "" || (m.vim && m.normalMode)) {
+		m.showHelp = true
+		return nil, true
+	}
+	return nil, false
+}
+
+// helpView renders the help overlay in place of the search view.
+func (m model) helpView() string {
+	var sb strings.Builder
+	sb.WriteString("\n  Keybindings (? or Esc to close)\n")
+
+	section := func(title string, bindings []keyHelp) {
+		sb.WriteString(fmt.Sprintf("\n  \033[1m%s\033[0m\n", title))
+		for _, b := range bindings {
+			sb.WriteString(fmt.Sprintf("    %-18s %s\n", b.keys, b.desc))
+		}
+	}
+	for _, s := range helpSections {
+		section(s.title, s.bindings)
+	}
+	if m.vim {
+		section("Vim mode", vimHelp)
+	}
+	return sb.String()
+}
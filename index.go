@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// INDEX FILES
+// ---------------------------------------------
+
+// getIndexFilePath returns the index file for name: "index" for the
+// default index and "index-<name>" for a named one, in the data directory.
+// An index from before the data directory, ~/.index or ~/.index-<name>,
+// is used instead as long as it exists.
+func getIndexFilePath(name string) (string, error) {
+	file := "index"
+	if name != "" {
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return "", fmt.Errorf("invalid index name %q", name)
+		}
+		file += "-" + name
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		legacy := filepath.Join(home, "."+file)
+		if info, err := os.Stat(legacy); err == nil && info.Mode().IsRegular() {
+			return legacy, nil
+		}
+	}
+	dir, err := getDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// getDataDir returns the directory indexes are kept in: under
+// $XDG_DATA_HOME, by default ~/.local/share, on Unix systems,
+// ~/Library/Application Support on macOS and %LocalAppData% on Windows.
+func getDataDir() (string, error) {
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("cannot find data directory: %LocalAppData% is not set")
+		}
+	case "darwin", "ios":
+		// The same place os.UserConfigDir uses
+		cfg, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot find data directory: %w", err)
+		}
+		dir = cfg
+	default:
+		// The spec says to ignore a relative $XDG_DATA_HOME
+		if dir = os.Getenv("XDG_DATA_HOME"); !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("cannot find data directory: %w", err)
+			}
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	return filepath.Join(dir, "file-indexer"), nil
+}
+
+// defaultIndexName is how the unnamed default index is shown and listed.
+const defaultIndexName = "default"
+
+// indexDisplayName returns name, or defaultIndexName for the default index.
+func indexDisplayName(name string) string {
+	if name == "" {
+		return defaultIndexName
+	}
+	return name
+}
+
+// Files next to an index are named after it with one of these suffixes,
+// and listIndexNames leaves them out.
+const (
+	corruptSuffix = ".corrupt" // a corrupt index moved aside
+	lockSuffix    = ".lock"    // held while the index is being built
+)
+
+var sidecarSuffixes = []string{corruptSuffix, lockSuffix}
+
+// listIndexNames returns the names of the indexes in the data directory
+// and the legacy ones in the home directory, "" standing for the default
+// one.
+func listIndexNames() ([]string, error) {
+	type location struct {
+		dir, prefix string
+	}
+	var locations []location
+	if dir, err := getDataDir(); err == nil {
+		locations = append(locations, location{dir, "index"})
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		locations = append(locations, location{home, ".index"})
+	}
+
+	var names []string
+	for _, loc := range locations {
+		files, err := os.ReadDir(loc.dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !f.Type().IsRegular() {
+				continue
+			}
+			name, ok := strings.CutPrefix(f.Name(), loc.prefix)
+			if ok && name != "" {
+				name, ok = strings.CutPrefix(name, "-")
+				ok = ok && name != "" && !slices.ContainsFunc(sidecarSuffixes, func(suffix string) bool {
+					return strings.HasSuffix(name, suffix)
+				})
+			}
+			if ok && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no indexes found")
+	}
+	return names, nil
+}
+
+// mergeIndexes combines idxs, named by names, into one index. A path found
+// in several indexes is kept once, from the first. The returned map gives
+// the display name of the index each path came from.
+func mergeIndexes(names []string, idxs []*indexer.Index) (*indexer.Index, map[string]string) {
+	merged := &indexer.Index{}
+	sources := make(map[string]string)
+	for i, idx := range idxs {
+		for _, root := range idx.Roots {
+			if !slices.Contains(merged.Roots, root) {
+				merged.Roots = append(merged.Roots, root)
+			}
+		}
+		merged.IncludeHidden = merged.IncludeHidden || idx.IncludeHidden
+		for _, e := range idx.Entries {
+			if _, dup := sources[e.Path]; dup {
+				continue
+			}
+			sources[e.Path] = indexDisplayName(names[i])
+			merged.Entries = append(merged.Entries, e)
+		}
+	}
+	return merged, sources
+}
+
+// buildOptions completes opts for a build of the index at savePath: an
+// incremental build reuses the index already there, as does any build
+// after one that was interrupted, and progress goes to stdout unless
+// running quietly.
+func buildOptions(savePath string, opts indexer.Options) indexer.Options {
+	if !quiet() {
+		opts.Output = os.Stdout
+	}
+	// A missing or unreadable index only means a full build
+	prev, _ := indexer.Load(savePath)
+	switch {
+	case opts.Incremental:
+		opts.Previous = prev
+	case prev != nil && prev.Partial:
+		printf("Resuming the interrupted build...\n")
+		opts.Incremental, opts.Previous = true, prev
+	}
+	return opts
+}
+
+// errPartialIndex reports that a build was interrupted and what it had
+// found so far was saved.
+var errPartialIndex = errors.New("interrupted, saved a partial index (run index again to finish it)")
+
+// walkIndex builds the index for roots without saving it, stopping early
+// once ctx is done.
+func walkIndex(ctx context.Context, savePath string, roots []string, opts indexer.Options) (*indexer.Index, error) {
+	return indexer.BuildContext(ctx, roots, buildOptions(savePath, opts))
+}
+
+// buildIndex walks roots and saves the resulting index to savePath. When
+// ctx is done before the walk has finished, the partial index is saved and
+// errPartialIndex returned. It fails with errIndexLocked while another
+// process is building the same index.
+func buildIndex(ctx context.Context, savePath string, roots []string, opts indexer.Options) error {
+	unlock, err := lockIndex(savePath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	idx, err := walkIndex(ctx, savePath, roots, opts)
+	if err != nil {
+		return err
+	}
+	if err := indexer.Save(savePath, idx); err != nil {
+		return err
+	}
+	if idx.Partial {
+		return errPartialIndex
+	}
+	return nil
+}
+
+// errIndexLocked reports that another process holds an index's lock file.
+var errIndexLocked = errors.New("index is already being built by another process")
+
+// staleLockAge is how long a lock file can go untouched before it's taken
+// to be left over from a build that crashed. A running build touches its
+// lock every lockRefreshInterval.
+const (
+	staleLockAge        = 5 * time.Minute
+	lockRefreshInterval = time.Minute
+)
+
+// lockIndex takes the lock file of the index at path, path.lock, returning
+// a function that releases it. A stale lock is taken over.
+func lockIndex(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot lock index: %w", err)
+	}
+	lock := path + lockSuffix
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) && !indexLocked(path) {
+		os.Remove(lock)
+		f, err = os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return nil, errIndexLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock index: %w", err)
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				os.Chtimes(lock, now, now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		os.Remove(lock)
+	}, nil
+}
+
+// indexLocked reports whether a build of the index at path holds its lock.
+func indexLocked(path string) bool {
+	info, err := os.Stat(path + lockSuffix)
+	return err == nil && time.Since(info.ModTime()) < staleLockAge
+}
+
+// reindexIfStale rebuilds the named index in a separate process when its
+// file, last written at modTime, is older than maxAge. The rebuild replays
+// the roots and options recorded in idx and outlives the UI, so the next
+// session finds a fresh index while this one searches idx. Indexes that
+// don't record their options, and ones already being rebuilt, are left
+// alone.
+func reindexIfStale(name string, idx *indexer.Index, modTime time.Time, maxAge time.Duration) {
+	if maxAge <= 0 || time.Since(modTime) <= maxAge {
+		return
+	}
+	opts, ok := idx.BuildOptions()
+	if !ok {
+		logger.Debug("not rebuilding stale index, it doesn't record how it was built", "index", indexDisplayName(name))
+		return
+	}
+	if path, err := getIndexFilePath(name); err == nil && indexLocked(path) {
+		logger.Debug("stale index is already being rebuilt", "index", indexDisplayName(name))
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		logger.Debug("cannot rebuild stale index", "index", indexDisplayName(name), "err", err)
+		return
+	}
+	args := []string{"index", "--incremental", "--quiet"}
+	if name != "" {
+		args = append(args, "--name", name)
+	}
+	args = append(args, indexFlags(opts)...)
+	args = append(append(args, "--"), idx.Roots...)
+
+	// Without stdin, stdout or stderr it can't draw over the UI
+	cmd := exec.Command(exe, args...)
+	if err := cmd.Start(); err != nil {
+		logger.Debug("cannot rebuild stale index", "index", indexDisplayName(name), "err", err)
+		return
+	}
+	logger.Debug("rebuilding stale index in the background", "index", indexDisplayName(name), "age", time.Since(modTime).Round(time.Minute), "pid", cmd.Process.Pid)
+	cmd.Process.Release()
+}
+
+// indexFlags returns the index command flags that build with opts.
+func indexFlags(opts indexer.Options) []string {
+	// An empty --exclude first replaces the default patterns
+	args := []string{"--exclude="}
+	for _, pattern := range opts.Excludes {
+		args = append(args, "--exclude", pattern)
+	}
+	if opts.UseGitignore {
+		args = append(args, "--use-gitignore")
+	}
+	args = append(args, "--max-depth", strconv.Itoa(opts.MaxDepth))
+	if opts.FollowSymlinks {
+		args = append(args, "--follow-symlinks")
+	}
+	if opts.IncludeHidden {
+		args = append(args, "--include-hidden")
+	}
+	for _, ext := range opts.IncludeExts {
+		args = append(args, "--include-ext", ext)
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", opts.Since.Format(time.RFC3339))
+	}
+	if opts.MaxFileSize > 0 {
+		args = append(args, "--max-file-size", strconv.FormatInt(opts.MaxFileSize, 10))
+	}
+	if opts.InterleaveRoots {
+		args = append(args, "--interleave-roots")
+	}
+	if opts.DedupInodes {
+		args = append(args, "--dedup-inodes")
+	}
+	return args
+}
+
+// moveAside renames a corrupt index to path.corrupt, replacing any earlier
+// one, so it can be inspected after a rebuild has replaced it.
+func moveAside(path string) (string, error) {
+	aside := path + corruptSuffix
+	if err := os.Rename(path, aside); err != nil {
+		return "", err
+	}
+	return aside, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"filesearcher/indexer"
+)
+
+func TestIndexFlagsReplayOptions(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		opts indexer.Options
+	}{
+		{"defaults", indexer.DefaultOptions()},
+		{"no excludes", indexer.Options{Excludes: []string{}, MaxDepth: -1}},
+		{"everything", indexer.Options{
+			Excludes:        []string{"build", "*.tmp", "src/vendor"},
+			UseGitignore:    true,
+			MaxDepth:        3,
+			FollowSymlinks:  true,
+			IncludeHidden:   true,
+			IncludeExts:     []string{".go", ".md"},
+			Since:           since,
+			MaxFileSize:     500 << 20,
+			InterleaveRoots: true,
+			DedupInodes:     true,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := flag.NewFlagSet("index", flag.ContinueOnError)
+			indexOpts := addIndexFlags(fset, defaultConfig())
+			if err := fset.Parse(indexFlags(tt.opts)); err != nil {
+				t.Fatal(err)
+			}
+			got := indexOpts()
+			if got.Excludes == nil {
+				got.Excludes = []string{}
+			}
+			want := tt.opts
+			if want.Excludes == nil {
+				want.Excludes = []string{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("replayed options\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestListIndexNamesWhileLocked(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("LocalAppData", dir)
+	for _, name := range []string{"", "work"} {
+		path, err := getIndexFilePath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := indexer.Save(path, &indexer.Index{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := getIndexFilePath("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := os.WriteFile(path+corruptSuffix, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := listIndexNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "work"}; !slices.Equal(names, want) {
+		t.Errorf("listIndexNames() = %q, want %q", names, want)
+	}
+}
+
+func TestLockIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	unlock, err := lockIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockIndex(path); !errors.Is(err, errIndexLocked) {
+		t.Fatalf("second lock: %v, want errIndexLocked", err)
+	}
+	if !indexLocked(path) {
+		t.Error("indexLocked = false while held")
+	}
+	unlock()
+	if indexLocked(path) {
+		t.Error("indexLocked = true after unlock")
+	}
+
+	// A lock left by a build that crashed is taken over
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.WriteFile(path+".lock", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = lockIndex(path)
+	if err != nil {
+		t.Fatalf("stale lock: %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,65 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+)
+
+// ---------------------------------------------
+// CONTENT SEARCH
+// ---------------------------------------------
+
+// contentSizeLimit is the largest file content: terms are looked for in;
+// bigger files never match one.
+const contentSizeLimit = 4 << 20
+
+// binarySniffLen is how much of a file is checked for a NUL byte, which
+// marks it as binary, as git does.
+const binarySniffLen = 8000
+
+// matchContent reports whether the file of e contains every content: term.
+// It reads the file, so the search modes check it only once the path has
+// matched.
+func (pq parsedQuery) matchContent(ctx context.Context, e *FileEntry, opts SearchOptions) bool {
+	if len(pq.contents) == 0 {
+		return true
+	}
+	// A cancelled search stops reading files straight away
+	return ctx.Err() == nil && containsAll(e.Path, pq.contents, opts)
+}
+
+// containsAll reports whether the text file at path contains every one of
+// terms, which are case-folded like the query. Files that are binary,
+// larger than contentSizeLimit or unreadable never match.
+func containsAll(path string, terms []string, opts SearchOptions) bool {
+	// Opening a FIFO or device could block or have side effects
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > contentSizeLimit {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// The file may have grown since the Stat
+	data, err := io.ReadAll(io.LimitReader(f, contentSizeLimit+1))
+	if err != nil || len(data) > contentSizeLimit {
+		return false
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return false
+	}
+	if !opts.CaseSensitive {
+		data = bytes.ToLower(data)
+	}
+	for _, term := range terms {
+		if !bytes.Contains(data, []byte(term)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package indexer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------
+// GITIGNORE MATCHING
+// ---------------------------------------------
+
+// ignoreRule is a single compiled line from a .gitignore file.
+type ignoreRule struct {
+	base     string // directory containing the .gitignore
+	re       *regexp.Regexp
+	negate   bool // "!pattern" re-includes a previously ignored path
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // a slash inside the pattern anchors it to base
+}
+
+func (r ignoreRule) match(path string) bool {
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !r.anchored {
+		rel = rel[strings.LastIndex(rel, "/")+1:]
+	}
+	return r.re.MatchString(rel)
+}
+
+// gitignore accumulates .gitignore rules for the directories visited during
+// a walk. Each directory inherits its parent's rules, followed by its own,
+// so deeper files override shallower ones.
+type gitignore struct {
+	rules map[string][]ignoreRule
+}
+
+func newGitignore() *gitignore {
+	return &gitignore{rules: make(map[string][]ignoreRule)}
+}
+
+// fork returns a matcher that starts from the rules already loaded for
+// root, so independent workers can each walk one of its subtrees.
+func (g *gitignore) fork(root string) *gitignore {
+	return &gitignore{rules: map[string][]ignoreRule{root: g.rules[root]}}
+}
+
+// enter loads dir/.gitignore, if any, on top of the rules inherited from
+// the parent directory, returning its modification time or zero if there
+// is none. It must be called before dir's children are checked.
+func (g *gitignore) enter(dir string) time.Time {
+	inherited := g.rules[filepath.Dir(dir)]
+	own, mod := parseGitignore(dir)
+	if len(own) == 0 {
+		g.rules[dir] = inherited
+		return mod
+	}
+	combined := make([]ignoreRule, 0, len(inherited)+len(own))
+	combined = append(combined, inherited...)
+	g.rules[dir] = append(combined, own...)
+	return mod
+}
+
+// ignored reports whether path is excluded by the rules in effect for its
+// parent directory. As in git, the last matching rule wins.
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range g.rules[filepath.Dir(path)] {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.match(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// parseGitignore reads the rules of dir/.gitignore along with its
+// modification time, both zero if it can't be read.
+func parseGitignore(dir string) ([]ignoreRule, time.Time) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil, time.Time{}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(dir, sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules, info.ModTime()
+}
+
+func parseIgnoreLine(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	r := ignoreRule{base: dir}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile(globToRegexp(line))
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates gitignore glob syntax, including "**", into an
+// anchored regular expression over slash-separated paths.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
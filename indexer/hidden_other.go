@@ -0,0 +1,17 @@
+//go:build !windows
+
+package indexer
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// ---------------------------------------------
+// HIDDEN FILES (UNIX)
+// ---------------------------------------------
+
+// isHidden reports whether d is hidden by the dotfile convention.
+func isHidden(d fs.DirEntry) bool {
+	return strings.HasPrefix(d.Name(), ".")
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package indexer
+
+import (
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// ---------------------------------------------
+// HIDDEN FILES (WINDOWS)
+// ---------------------------------------------
+
+// isHidden reports whether d is hidden, either by the dotfile convention
+// or by carrying the hidden attribute, which is how Windows marks system
+// directories such as AppData.
+func isHidden(d fs.DirEntry) bool {
+	if strings.HasPrefix(d.Name(), ".") {
+		return true
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
@@ -0,0 +1,1094 @@
+// Package indexer builds, stores and searches an index of the files under
+// a set of root directories. The file-indexer command is a front-end to it;
+// other programs can embed it the same way:
+//
+//	idx, err := indexer.Build([]string{"/src"}, indexer.DefaultOptions())
+//	...
+//	matches, _ := indexer.Search(idx.Entries, "main.go", indexer.SearchOptions{Limit: 10})
+//	for _, i := range matches {
+//		fmt.Println(idx.Entries[i].Path)
+//	}
+//
+// Build returns an *Index rather than a bare []FileEntry because the index
+// also records its roots, build time and the options it was built with,
+// which Save, Load and incremental rebuilds need. Search returns positions
+// into the entries it was given instead of a []Result of copies, so a search
+// over millions of files allocates next to nothing; look the entries up by
+// position as above.
+package indexer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// INDEXING & FS LOGIC
+// ---------------------------------------------
+
+// FileEntry is a single indexed file and the metadata captured for it.
+type FileEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Root    string    `json:"root,omitempty"` // the index root the file was found under
+}
+
+// indexMagic and indexVersion identify the on-disk format. Bump the
+// version whenever index changes in a way older builds can't read.
+const (
+	indexMagic   = "file-indexer"
+	indexVersion = 1
+)
+
+// indexHeader precedes the index in the file, so its format can be checked
+// before the index itself is decoded.
+type indexHeader struct {
+	Magic   string
+	Version int
+}
+
+// Index is a built index, as written by Save and read by Load.
+type Index struct {
+	Roots   []string
+	Entries []FileEntry
+
+	// Dirs records the modification time of every directory walked, which
+	// lets an incremental build reuse the entries of unchanged directories.
+	Dirs map[string]time.Time
+
+	// Ignores records the modification time of the .gitignore read in each
+	// directory that has one, so an incremental build rewalks the subtrees
+	// whose rules changed.
+	Ignores map[string]time.Time
+
+	// UseGitignore and FollowSymlinks record the Options of the same names.
+	UseGitignore   bool
+	FollowSymlinks bool
+
+	// IncludeHidden records that hidden files and directories were indexed.
+	IncludeHidden bool
+
+	// IncludeExts records the extensions indexing was limited to, if any.
+	IncludeExts []string
+
+	// Since records the modification time files had to reach to be
+	// indexed; zero if every file was.
+	Since time.Time
+
+	// MaxFileSize records the size files could be at most to be indexed;
+	// zero if there was no limit.
+	MaxFileSize int64
+
+	// InterleaveRoots records that the entries of the roots alternate.
+	InterleaveRoots bool
+
+	// Excludes, MaxDepth and DedupInodes record the Options of the same
+	// names. Excludes includes any patterns the caller added, such as
+	// those of a roots file.
+	Excludes    []string
+	MaxDepth    int
+	DedupInodes bool
+
+	// OptionsRecorded is set on indexes that record every setting they
+	// were built with; older ones lack Excludes, MaxDepth and DedupInodes.
+	OptionsRecorded bool
+
+	// Partial records that the build was interrupted, so some files are
+	// missing. Dirs then only holds the directories walked in full, which
+	// lets an incremental build pick up where it stopped.
+	Partial bool
+
+	// Files holds bare paths from indexes written before entries carried
+	// metadata. Load converts it into Entries; it is never written.
+	Files []string
+}
+
+// Options controls which parts of the roots Build walks.
+type Options struct {
+	// Excludes are glob patterns matched against a directory's base name
+	// and its slash-separated path relative to the root.
+	Excludes []string
+
+	// UseGitignore skips paths matched by .gitignore files found during the walk.
+	UseGitignore bool
+
+	// Incremental reuses the entries of Previous for every directory whose
+	// modification time is unchanged instead of stat'ing its files.
+	Incremental bool
+
+	// Previous is the last build of the same index, for incremental builds.
+	// Without it they run a full build.
+	Previous *Index
+
+	// MaxDepth limits how many directory levels below each root are
+	// descended into; 0 indexes only the root's own files and a negative
+	// value means no limit.
+	MaxDepth int
+
+	// FollowSymlinks descends into symlinked directories and indexes
+	// symlinked files. Each link target is walked at most once.
+	FollowSymlinks bool
+
+	// IncludeHidden indexes hidden directories, which are skipped by default.
+	IncludeHidden bool
+
+	// IncludeExts, when not empty, limits indexing to files with one of
+	// these lowercased extensions, each including the dot.
+	IncludeExts []string
+
+	// Since, when not zero, skips files last modified before it.
+	// Directories are still walked.
+	Since time.Time
+
+	// MaxFileSize, when positive, skips files larger than this many bytes.
+	// Directories are still walked.
+	MaxFileSize int64
+
+	// InterleaveRoots orders the entries of several roots round-robin, one
+	// from each root in turn, instead of all of one root before the next,
+	// so the unsorted list samples every root from the top.
+	InterleaveRoots bool
+
+	// DedupInodes keeps one entry per file when the same file is reachable
+	// by several paths, through symlinks, hard links or aliased roots.
+	DedupInodes bool
+
+	// Verbose lists every path that couldn't be read instead of only
+	// counting them.
+	Verbose bool
+
+	// Output receives progress and a summary of the build; nil discards
+	// them.
+	Output io.Writer
+}
+
+// DefaultOptions skips node_modules and .git and has no depth limit.
+func DefaultOptions() Options {
+	return Options{Excludes: []string{"node_modules", ".git"}, MaxDepth: -1}
+}
+
+// printf writes progress and summaries to o.Output, if set.
+func (o Options) printf(format string, args ...any) {
+	if o.Output != nil {
+		fmt.Fprintf(o.Output, format, args...)
+	}
+}
+
+// Build walks roots, or the home directory when there are none, and
+// returns the index without saving it.
+func Build(roots []string, opts Options) (*Index, error) {
+	return BuildContext(context.Background(), roots, opts)
+}
+
+// BuildContext is Build that stops walking once ctx is done, returning the
+// files found so far in an index marked Partial.
+func BuildContext(ctx context.Context, roots []string, opts Options) (*Index, error) {
+	if len(roots) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get home directory: %w", err)
+		}
+		roots = []string{home}
+	}
+
+	roots, err := normalizeRoots(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *previousIndex
+	if opts.Incremental {
+		switch old := opts.Previous; {
+		case old == nil:
+			opts.printf("No usable previous index, running a full build.\n")
+		case !old.Since.IsZero() && (opts.Since.IsZero() || old.Since.After(opts.Since)):
+			// Its unchanged directories would lack the older files now wanted
+			opts.printf("Previous index covers a shorter period, running a full build.\n")
+		case old.MaxFileSize > 0 && (opts.MaxFileSize <= 0 || old.MaxFileSize < opts.MaxFileSize):
+			opts.printf("Previous index left out larger files, running a full build.\n")
+		case len(old.IncludeExts) > 0 && (len(opts.IncludeExts) == 0 || !subsetOf(opts.IncludeExts, old.IncludeExts)):
+			opts.printf("Previous index left out other extensions, running a full build.\n")
+		case old.UseGitignore != opts.UseGitignore || old.FollowSymlinks != opts.FollowSymlinks:
+			// Its unchanged directories would hold files now ignored or
+			// links now followed, or lack the reverse
+			opts.printf("Previous index used other .gitignore or symlink settings, running a full build.\n")
+		default:
+			prev = newPreviousIndex(old)
+		}
+	}
+
+	opts.printf("Indexing %s...\n", strings.Join(roots, ", "))
+	start := time.Now()
+
+	results := make(chan walkResult, 1024)
+	jobs := make(chan walkJob)
+
+	var links *symlinkGuard
+	if opts.FollowSymlinks {
+		links = newSymlinkGuard(roots)
+	}
+
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				// Errors are handled per entry inside visit
+				_ = filepath.WalkDir(j.dir, j.w.visit)
+				j.w.leaveAll()
+			}
+		}()
+	}
+
+	// Fan out: each top-level subdirectory of every root is its own job
+	var walkErr error
+	go func() {
+		defer close(results)
+		for _, root := range roots {
+			w := newWalker(ctx, root, opts, prev, links, results)
+			if walkErr = w.walkTop(jobs); walkErr != nil {
+				break
+			}
+		}
+		close(jobs)
+		wg.Wait()
+	}()
+
+	var files []FileEntry
+	var infos []fs.FileInfo // parallel to files, only when deduplicating inodes
+	var walkErrs []error
+	dirs := make(map[string]time.Time)
+	ignores := make(map[string]time.Time)
+	reused := 0
+
+	// Progress is redrawn on a timer, independent of how fast files arrive
+	prog := newProgress(opts.Output)
+	ticker := time.NewTicker(prog.interval)
+	defer ticker.Stop()
+
+collect:
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				break collect
+			}
+			if r.err != nil {
+				walkErrs = append(walkErrs, r.err)
+				continue
+			}
+			if r.dir != "" {
+				dirs[r.dir] = r.dirMod
+				if !r.ignoreMod.IsZero() {
+					ignores[r.dir] = r.ignoreMod
+				}
+				if r.reused {
+					reused++
+				}
+				continue
+			}
+			files = append(files, r.entry)
+			if opts.DedupInodes {
+				infos = append(infos, r.info)
+			}
+		case <-ticker.C:
+			prog.update(len(files))
+		}
+	}
+	prog.done()
+	if walkErr != nil {
+		return nil, fmt.Errorf("walk error: %w", walkErr)
+	}
+
+	walked := len(files)
+	files = dedupEntries(files, infos)
+	if opts.InterleaveRoots {
+		files = interleaveRoots(files, roots)
+	}
+	partial := ctx.Err() != nil
+	if partial {
+		opts.printf("Interrupted! Indexed %d files in %v before stopping\n", len(files), time.Since(start))
+	} else {
+		opts.printf("Finished! Indexed %d files in %v\n", len(files), time.Since(start))
+	}
+	if dups := walked - len(files); dups > 0 {
+		opts.printf("Dropped %d duplicate entries\n", dups)
+	}
+	if prev != nil {
+		opts.printf("Reused %d of %d directories unchanged since the last build\n", reused, len(dirs))
+	}
+	if len(walkErrs) > 0 {
+		opts.printf("Skipped %d directories due to errors", len(walkErrs))
+		if !opts.Verbose {
+			opts.printf(" (use --verbose to list them)\n")
+		} else {
+			opts.printf(":\n")
+			for _, err := range walkErrs {
+				opts.printf("  %v\n", err)
+			}
+		}
+	}
+	idx := newIndex(roots, opts)
+	idx.Entries, idx.Dirs, idx.Ignores, idx.Partial = files, dirs, ignores, partial
+	return idx, nil
+}
+
+// newIndex returns an empty index of roots recording the settings of opts.
+func newIndex(roots []string, opts Options) *Index {
+	return &Index{
+		Roots:           roots,
+		UseGitignore:    opts.UseGitignore,
+		FollowSymlinks:  opts.FollowSymlinks,
+		IncludeHidden:   opts.IncludeHidden,
+		IncludeExts:     opts.IncludeExts,
+		Since:           opts.Since,
+		MaxFileSize:     max(opts.MaxFileSize, 0),
+		InterleaveRoots: opts.InterleaveRoots,
+		Excludes:        opts.Excludes,
+		MaxDepth:        opts.MaxDepth,
+		DedupInodes:     opts.DedupInodes,
+		OptionsRecorded: true,
+	}
+}
+
+// BuildOptions returns the Options idx was built with, for building it
+// again, or false when idx is too old to record all of them.
+func (idx *Index) BuildOptions() (Options, bool) {
+	if !idx.OptionsRecorded {
+		return Options{}, false
+	}
+	return Options{
+		Excludes:        idx.Excludes,
+		UseGitignore:    idx.UseGitignore,
+		MaxDepth:        idx.MaxDepth,
+		FollowSymlinks:  idx.FollowSymlinks,
+		IncludeHidden:   idx.IncludeHidden,
+		IncludeExts:     idx.IncludeExts,
+		Since:           idx.Since,
+		MaxFileSize:     idx.MaxFileSize,
+		InterleaveRoots: idx.InterleaveRoots,
+		DedupInodes:     idx.DedupInodes,
+	}, true
+}
+
+// walkResult is either an indexed file, or when dir is set a directory
+// whose files have all been walked together with its modification time,
+// or when err is set a path that couldn't be read.
+type walkResult struct {
+	entry     FileEntry
+	info      fs.FileInfo // entry's file, only with DedupInodes and never for reused entries
+	dir       string
+	dirMod    time.Time
+	ignoreMod time.Time // of dir's .gitignore, zero if it has none or it isn't read
+	reused    bool      // dir's files were copied from the previous index
+	err       error
+}
+
+// previousIndex is the lookup an incremental build consults: directory
+// and .gitignore mtimes and the files last indexed directly inside each
+// directory.
+type previousIndex struct {
+	dirs    map[string]time.Time
+	ignores map[string]time.Time
+	files   map[string][]FileEntry
+}
+
+func newPreviousIndex(idx *Index) *previousIndex {
+	p := &previousIndex{dirs: idx.Dirs, ignores: idx.Ignores, files: make(map[string][]FileEntry)}
+	for _, e := range idx.Entries {
+		dir := filepath.Dir(e.Path)
+		p.files[dir] = append(p.files[dir], e)
+	}
+	return p
+}
+
+// unchanged reports whether dir was walked last time with the same mtime.
+// Adding, removing or renaming an entry always bumps a directory's mtime.
+func (p *previousIndex) unchanged(dir string, mod time.Time) bool {
+	last, ok := p.dirs[dir]
+	return ok && last.Equal(mod)
+}
+
+// walkJob is a subtree handed to an indexing worker.
+type walkJob struct {
+	w   *walker
+	dir string
+}
+
+// walker applies Options to the tree under a single root and sends
+// every accepted file to out. A walker is not safe for concurrent use;
+// fork gives each worker its own copy.
+type walker struct {
+	ctx    context.Context // the walk stops once it is done
+	root   string
+	opts   Options
+	ignore *gitignore
+	out    chan<- walkResult
+
+	// rulesChanged holds the directories whose .gitignore rules, their own
+	// or inherited, differ from those of the previous build, so none of
+	// their files may be reused.
+	rulesChanged map[string]bool
+
+	// open holds the directories being walked, innermost last. Each is
+	// sent to out once the walk has left it, so an interrupted build
+	// only records directories it has finished.
+	open []walkResult
+
+	// prev is the previous index for incremental builds, or nil. reused
+	// holds the directories whose files were copied from it.
+	prev   *previousIndex
+	reused map[string]bool
+
+	// links is shared by every walker of a build; nil unless symlinks
+	// are followed.
+	links *symlinkGuard
+}
+
+func newWalker(ctx context.Context, root string, opts Options, prev *previousIndex, links *symlinkGuard, out chan<- walkResult) *walker {
+	w := &walker{ctx: ctx, root: root, opts: opts, out: out, prev: prev, reused: make(map[string]bool), rulesChanged: make(map[string]bool), links: links}
+	if opts.UseGitignore {
+		w.ignore = newGitignore()
+	}
+	return w
+}
+
+// fork returns a walker for one subtree of the root that shares the root's
+// settings but tracks .gitignore rules independently.
+func (w *walker) fork() *walker {
+	f := *w
+	if w.ignore != nil {
+		f.ignore = w.ignore.fork(w.root)
+	}
+	f.reused = make(map[string]bool)
+	f.rulesChanged = map[string]bool{w.root: w.rulesChanged[w.root]}
+	f.open = nil
+	return &f
+}
+
+// walkTop visits the root's immediate entries, indexing its files directly
+// and queueing each subdirectory that survives the skip rules as a job.
+func (w *walker) walkTop(jobs chan<- walkJob) error {
+	info, err := os.Stat(w.root)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(w.root)
+	if err != nil {
+		return err
+	}
+	w.enterDir(w.root, info.ModTime())
+	for _, d := range entries {
+		if w.ctx.Err() != nil {
+			return nil
+		}
+		path := filepath.Join(w.root, d.Name())
+		if d.IsDir() {
+			if !w.skipDir(path, d) {
+				jobs <- walkJob{w.fork(), path}
+			}
+			continue
+		}
+		_ = w.visit(path, d, nil)
+	}
+	// The root's own files are done; its subdirectories are separate jobs
+	w.leaveAll()
+	return nil
+}
+
+// skipDir reports whether the directory at path is excluded by the depth
+// limit or the hidden directory, Excludes or .gitignore rules. The root is never
+// skipped.
+func (w *walker) skipDir(path string, d fs.DirEntry) bool {
+	if path == w.root {
+		return false
+	}
+	if w.opts.MaxDepth >= 0 && dirDepth(w.root, path) > w.opts.MaxDepth {
+		return true
+	}
+	if !w.opts.IncludeHidden && isHidden(d) {
+		return true
+	}
+	if isExcluded(w.root, path, w.opts.Excludes) {
+		return true
+	}
+	return w.ignore != nil && w.ignore.ignored(path, true)
+}
+
+// enterDir records a directory about to be walked, loading its .gitignore
+// and, when neither it nor the rules in effect for it changed since the
+// previous build, emitting its old entries so its files need not be
+// stat'ed again.
+func (w *walker) enterDir(path string, mod time.Time) {
+	var ignoreMod time.Time
+	if w.ignore != nil {
+		ignoreMod = w.ignore.enter(path)
+		if w.prev != nil && (w.rulesChanged[filepath.Dir(path)] || !w.prev.ignores[path].Equal(ignoreMod)) {
+			w.rulesChanged[path] = true
+		}
+	}
+	reused := w.prev != nil && w.prev.unchanged(path, mod) && !w.rulesChanged[path]
+	if reused {
+		w.reused[path] = true
+		for _, e := range w.prev.files[path] {
+			// The previous build may have allowed other extensions or an
+			// older modification time
+			if w.wantsExt(e.Path) && w.recentEnough(e.ModTime) && w.smallEnough(e.Size) {
+				w.out <- walkResult{entry: e}
+			}
+		}
+	}
+	w.open = append(w.open, walkResult{dir: path, dirMod: mod, ignoreMod: ignoreMod, reused: reused})
+}
+
+// leaveDirs sends the open directories that path is outside of, as the
+// walk visits paths in lexical order and so won't return to them.
+func (w *walker) leaveDirs(path string) {
+	for n := len(w.open); n > 0 && !inDir(w.open[n-1].dir, path); n-- {
+		w.out <- w.open[n-1]
+		w.open = w.open[:n-1]
+	}
+}
+
+// inDir is a cheaper IsWithin for the clean paths of a walk: it reports
+// whether path lies below dir.
+func inDir(dir, path string) bool {
+	if !strings.HasPrefix(path, dir) || len(path) == len(dir) {
+		return false
+	}
+	return os.IsPathSeparator(path[len(dir)]) || os.IsPathSeparator(dir[len(dir)-1])
+}
+
+// leaveAll sends the open directories once their walk has ended, unless it
+// ended by being interrupted and so may have missed some of their files.
+func (w *walker) leaveAll() {
+	if w.ctx.Err() == nil {
+		for _, r := range slices.Backward(w.open) {
+			w.out <- r
+		}
+	}
+	w.open = nil
+}
+
+// visit is the fs.WalkDirFunc shared by every worker.
+func (w *walker) visit(path string, d fs.DirEntry, err error) error {
+	if w.ctx.Err() != nil {
+		return filepath.SkipAll
+	}
+	w.leaveDirs(path)
+	if err != nil {
+		// Typically an unreadable directory: report it and walk on
+		w.out <- walkResult{err: err}
+		return nil
+	}
+	if d.IsDir() {
+		if w.skipDir(path, d) {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		w.enterDir(path, info.ModTime())
+		return nil
+	}
+	// Security: Skip symlinks unless asked to follow them. Checked before
+	// reuse because a linked directory's files aren't its parent's entries.
+	if d.Type()&os.ModeSymlink != 0 {
+		if w.links != nil {
+			w.followLink(path, d)
+		}
+		return nil
+	}
+	if w.reused[filepath.Dir(path)] {
+		return nil
+	}
+	if w.ignore != nil && w.ignore.ignored(path, false) {
+		return nil
+	}
+	info, err := d.Info()
+	if err != nil {
+		// Vanished between listing and stat
+		return nil
+	}
+	w.emit(path, info)
+	return nil
+}
+
+// emit sends the file at path, described by info, to the collector
+// unless its extension isn't wanted.
+func (w *walker) emit(path string, info fs.FileInfo) {
+	if !w.wantsExt(path) || !w.recentEnough(info.ModTime()) || !w.smallEnough(info.Size()) {
+		return
+	}
+	r := walkResult{entry: FileEntry{Path: path, Size: info.Size(), ModTime: info.ModTime(), Root: w.root}}
+	if w.opts.DedupInodes {
+		r.info = info
+	}
+	w.out <- r
+}
+
+// followLink indexes the target of the symlink at path under the link's
+// own path. Directories are walked in place, since queueing them could
+// deadlock a worker pool that is busy producing, and only if no other link
+// or root already covers the same real directory, which is what stops
+// circular links.
+func (w *walker) followLink(path string, d fs.DirEntry) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Dangling link
+		return
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return
+	}
+
+	if !info.IsDir() {
+		if w.reused[filepath.Dir(path)] || (w.ignore != nil && w.ignore.ignored(path, false)) {
+			return
+		}
+		w.emit(path, info)
+		return
+	}
+	if w.skipDir(path, d) || !w.links.claim(real) {
+		return
+	}
+	_ = filepath.WalkDir(real, func(p string, d fs.DirEntry, err error) error {
+		if p == real {
+			// The link itself passed skipDir; its target's name is irrelevant
+			w.enterDir(path, info.ModTime())
+			return nil
+		}
+		return w.visit(path+p[len(real):], d, err)
+	})
+}
+
+// symlinkGuard records the real directories a build has walked through a
+// symlink, so every link target is indexed once however many links lead
+// to it. Targets inside a root are never claimed: they are indexed anyway.
+type symlinkGuard struct {
+	mu      sync.Mutex
+	roots   []string
+	visited map[string]bool
+}
+
+func newSymlinkGuard(roots []string) *symlinkGuard {
+	g := &symlinkGuard{visited: make(map[string]bool)}
+	for _, root := range roots {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			g.roots = append(g.roots, real)
+		}
+	}
+	return g
+}
+
+// claim reports whether the directory real should be walked, marking it
+// as visited if so.
+func (g *symlinkGuard) claim(real string) bool {
+	for _, root := range g.roots {
+		if IsWithin(root, real) {
+			return false
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.visited[real] {
+		return false
+	}
+	g.visited[real] = true
+	return true
+}
+
+// recentEnough reports whether a file modified at mod is new enough for
+// Options.Since.
+func (w *walker) recentEnough(mod time.Time) bool {
+	return w.opts.Since.IsZero() || !mod.Before(w.opts.Since)
+}
+
+// smallEnough reports whether a file of size bytes is within
+// Options.MaxFileSize.
+func (w *walker) smallEnough(size int64) bool {
+	return w.opts.MaxFileSize <= 0 || size <= w.opts.MaxFileSize
+}
+
+// wantsExt reports whether path has an extension the index is limited to,
+// which is any extension when there is no limit.
+func (w *walker) wantsExt(path string) bool {
+	return len(w.opts.IncludeExts) == 0 || slices.Contains(w.opts.IncludeExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// NormalizeExts lowercases exts and gives each a leading dot, dropping
+// empty and repeated ones, so "GO" and ".go" both allow main.go.
+func NormalizeExts(exts []string) []string {
+	var out []string
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" && !slices.Contains(out, "."+ext) {
+			out = append(out, "."+ext)
+		}
+	}
+	return out
+}
+
+// subsetOf reports whether every element of a is also in b.
+func subsetOf(a, b []string) bool {
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupEntries drops repeated paths from files, keeping the first. With
+// infos, parallel to files, it also drops every entry os.SameFile reports
+// as a file already kept. Only files of equal size and mtime are compared.
+func dedupEntries(files []FileEntry, infos []fs.FileInfo) []FileEntry {
+	type fileKey struct {
+		size int64
+		mod  int64
+	}
+	seen := make(map[string]bool, len(files))
+	kept := make(map[fileKey][]fs.FileInfo)
+	out := files[:0]
+	for i, e := range files {
+		if seen[e.Path] {
+			continue
+		}
+		if infos != nil && infos[i] != nil {
+			info := infos[i]
+			k := fileKey{e.Size, e.ModTime.UnixNano()}
+			if slices.ContainsFunc(kept[k], func(o fs.FileInfo) bool { return os.SameFile(o, info) }) {
+				continue
+			}
+			kept[k] = append(kept[k], info)
+		}
+		seen[e.Path] = true
+		out = append(out, e)
+	}
+	return out
+}
+
+// interleaveRoots reorders files round-robin by their Root: the first file
+// of each root in the order of roots, then the second of each, and so on,
+// keeping the order of each root's own files. Files of other roots come
+// after those of roots, in the order they first appear.
+func interleaveRoots(files []FileEntry, roots []string) []FileEntry {
+	order := slices.Clone(roots)
+	byRoot := make(map[string][]FileEntry, len(roots))
+	for _, e := range files {
+		if _, ok := byRoot[e.Root]; !ok && !slices.Contains(order, e.Root) {
+			order = append(order, e.Root)
+		}
+		byRoot[e.Root] = append(byRoot[e.Root], e)
+	}
+	out := make([]FileEntry, 0, len(files))
+	for i := 0; len(out) < len(files); i++ {
+		for _, root := range order {
+			if i < len(byRoot[root]) {
+				out = append(out, byRoot[root][i])
+			}
+		}
+	}
+	return out
+}
+
+// expandPath resolves a leading ~ to the home directory and substitutes
+// $VAR and ${VAR}, and on Windows also %VAR%, so configured paths work
+// across machines. A variable that is unset or empty is an error rather
+// than silently leaving a path such as "/docs".
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot find home directory: %w", err)
+		}
+		path = home + path[1:]
+	}
+
+	var missing []string
+	lookup := func(name string) string {
+		v := os.Getenv(name)
+		if v == "" && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+		return v
+	}
+	path = os.Expand(path, lookup)
+	if runtime.GOOS == "windows" {
+		path = windowsVarPattern.ReplaceAllStringFunc(path, func(v string) string {
+			return lookup(v[1 : len(v)-1])
+		})
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return path, nil
+}
+
+// windowsVarPattern matches a %VAR% reference.
+var windowsVarPattern = regexp.MustCompile(`%[A-Za-z_][A-Za-z0-9_]*%`)
+
+// normalizeRoots makes every root absolute and drops roots that are nested
+// inside another one, so overlapping roots are only walked once.
+func normalizeRoots(roots []string) ([]string, error) {
+	abs := make([]string, 0, len(roots))
+	for _, root := range roots {
+		expanded, err := expandPath(root)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve root %q: %w", root, err)
+		}
+		p, err := filepath.Abs(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve root %q: %w", root, err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot index %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("cannot index %s: not a directory", p)
+		}
+		abs = append(abs, p)
+	}
+
+	var out []string
+	for i, p := range abs {
+		covered := false
+		for j, other := range abs {
+			if i == j {
+				continue
+			}
+			// Keep the first of two identical roots, drop nested ones
+			if IsWithin(other, p) && (other != p || j < i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// isExcluded reports whether the directory at path matches any exclude
+// pattern, either by base name or by its path relative to root.
+func isExcluded(root, path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	base := filepath.Base(path)
+	for _, p := range patterns {
+		if ok, _ := pathpkg.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := pathpkg.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dirDepth returns how many levels path lies below root, counted from the
+// relative path so it is the same whichever root path belongs to.
+func dirDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// IsWithin reports whether path is parent itself or lies beneath it.
+func IsWithin(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Save writes idx to a temporary file next to path and renames it
+// into place, so a crash mid-write leaves the previous index intact.
+func Save(path string, idx *Index) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("cannot create index directory: %w", err)
+	}
+	// Security: CreateTemp uses 0600 = Read/Write by owner only
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create index file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := Encode(f, idx); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cannot write index file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot write index file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("cannot replace index file: %w", err)
+	}
+	return nil
+}
+
+// Encode writes idx to w in the on-disk format: an indexHeader and
+// then the index, gob-encoded inside gzip.
+func Encode(w io.Writer, idx *Index) error {
+	zw := gzip.NewWriter(w)
+	enc := gob.NewEncoder(zw)
+	if err := enc.Encode(indexHeader{Magic: indexMagic, Version: indexVersion}); err != nil {
+		return fmt.Errorf("cannot encode index: %w", err)
+	}
+	if err := enc.Encode(idx); err != nil {
+		return fmt.Errorf("cannot encode index: %w", err)
+	}
+	// Close flushes the compressed stream, so its error matters
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("cannot write index file: %w", err)
+	}
+	return nil
+}
+
+// ErrCorrupt is returned by Load for a file that exists but
+// can't be decoded in any known format.
+var ErrCorrupt = errors.New("invalid index")
+
+// ErrTooNew is returned by Load for an index whose header says
+// it was written by a newer version of the format.
+var ErrTooNew = errors.New("index written by a newer version of file-indexer")
+
+// errNoHeader means the file doesn't start with an indexHeader, as is the
+// case for indexes written before the header was introduced.
+var errNoHeader = errors.New("index has no version header")
+
+// Load reads the index saved at path by Save, upgrading indexes written in
+// older formats. It returns ErrCorrupt for a file it can't decode and
+// ErrTooNew for one written by a newer version.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open index file: %w", err)
+	}
+	defer f.Close()
+
+	var idx Index
+	err = decodeIndex(f, &idx)
+	if errors.Is(err, ErrTooNew) {
+		return nil, err
+	}
+	// Legacy: indexes from before the header are a bare index value, and
+	// those from before roots were stored a bare []string
+	if errors.Is(err, errNoHeader) {
+		idx = Index{}
+		err = rereadUnversioned(f, &idx)
+	}
+	if err != nil {
+		var files []string
+		if rereadUnversioned(f, &files) != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+		}
+		idx = Index{Files: files}
+	}
+	if len(idx.Entries) == 0 && len(idx.Files) > 0 {
+		idx.Entries = make([]FileEntry, len(idx.Files))
+		for i, p := range idx.Files {
+			idx.Entries[i] = FileEntry{Path: p}
+		}
+		idx.Files = nil
+	}
+	idx.shareRoots()
+	return &idx, nil
+}
+
+// shareRoots points every entry's Root at the matching string in Roots, so
+// a decoded index holds one copy of each root rather than one per entry,
+// and fills in the root of entries from indexes that didn't record it.
+func (idx *Index) shareRoots() {
+	for i := range idx.Entries {
+		e := &idx.Entries[i]
+		for _, root := range idx.Roots {
+			if e.Root == root || (e.Root == "" && IsWithin(root, e.Path)) {
+				e.Root = root
+				break
+			}
+		}
+	}
+}
+
+// decodeIndex reads an index written by Encode, checking its header
+// before decoding the rest.
+func decodeIndex(r io.Reader, idx *Index) error {
+	rd, err := indexReader(r)
+	if err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(rd)
+	var hdr indexHeader
+	if err := dec.Decode(&hdr); err != nil || hdr.Magic != indexMagic {
+		return errNoHeader
+	}
+	if hdr.Version > indexVersion {
+		return fmt.Errorf("%w (format %d, this build reads up to %d); upgrade or run `index` again",
+			ErrTooNew, hdr.Version, indexVersion)
+	}
+	// Older format versions are upgraded in memory here as the format evolves
+	return dec.Decode(idx)
+}
+
+// rereadUnversioned decodes f from the start as one of the formats written
+// before the header existed.
+func rereadUnversioned(f *os.File, v any) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	rd, err := indexReader(f)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(rd).Decode(v)
+}
+
+// indexReader returns r, transparently decompressed when it starts with
+// the gzip magic bytes. Indexes written before compression was added are
+// plain gob.
+func indexReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
@@ -0,0 +1,162 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeFiles creates each of names, slash-separated, under dir.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// indexedNames returns the paths of idx relative to root, sorted.
+func indexedNames(t *testing.T, idx *Index, root string) []string {
+	t.Helper()
+	var names []string
+	for _, e := range idx.Entries {
+		rel, err := filepath.Rel(root, e.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.ToSlash(rel))
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestIncrementalReappliesGitignore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, ".gitignore", "sub/a.log", "sub/b.txt", "sub/deeper/c.log")
+
+	opts := DefaultOptions()
+	opts.UseGitignore = true
+	first, err := Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the root's mtime changes: the subdirectories are untouched
+	gitignore := filepath.Join(root, ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(gitignore, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Incremental, opts.Previous = true, first
+	second, err := Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexedNames(t, second, root), []string{".gitignore", "sub/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("incremental build indexed %q, want %q", got, want)
+	}
+
+	// With the rules unchanged again, the directories are reused
+	opts.Previous = second
+	third, err := Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexedNames(t, third, root), []string{".gitignore", "sub/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("second incremental build indexed %q, want %q", got, want)
+	}
+}
+
+func TestIncrementalRebuildsForOtherGitignoreSetting(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "sub/a.log", "sub/b.txt")
+	if err := os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Build([]string{root}, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.UseGitignore, opts.Incremental, opts.Previous = true, true, first
+	second, err := Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexedNames(t, second, root), []string{"sub/.gitignore", "sub/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("incremental build indexed %q, want %q", got, want)
+	}
+}
+
+func TestIncrementalWidensExtensions(t *testing.T) {
+	tests := []struct {
+		name string
+		exts []string
+		want []string
+	}{
+		{"no filter", nil, []string{"sub/a.go", "sub/b.md", "sub/c.txt"}},
+		{"other extension", []string{".md"}, []string{"sub/b.md"}},
+		{"more extensions", []string{".go", ".md"}, []string{"sub/a.go", "sub/b.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, "sub/a.go", "sub/b.md", "sub/c.txt")
+
+			opts := DefaultOptions()
+			opts.IncludeExts = []string{".go"}
+			first, err := Build([]string{root}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = DefaultOptions()
+			opts.IncludeExts, opts.Incremental, opts.Previous = tt.exts, true, first
+			second, err := Build([]string{root}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := indexedNames(t, second, root); !slices.Equal(got, tt.want) {
+				t.Errorf("incremental build indexed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRecordsOptions(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a.go", "vendor/b.go")
+
+	opts := Options{Excludes: []string{"vendor"}, UseGitignore: true, MaxDepth: 2, DedupInodes: true, IncludeExts: []string{".go"}}
+	idx, err := Build([]string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Saved and loaded, as a later rebuild sees it
+	path := filepath.Join(t.TempDir(), "index")
+	if err := Save(path, idx); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := idx.BuildOptions()
+	if !ok || !slices.Equal(got.Excludes, opts.Excludes) || !got.UseGitignore || got.MaxDepth != 2 || !got.DedupInodes || !slices.Equal(got.IncludeExts, opts.IncludeExts) {
+		t.Errorf("BuildOptions() = %+v, %v, want %+v", got, ok, opts)
+	}
+
+	if _, ok := (&Index{Roots: []string{root}}).BuildOptions(); ok {
+		t.Error("BuildOptions() of an index without recorded options succeeded")
+	}
+}
@@ -0,0 +1,66 @@
+package indexer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ---------------------------------------------
+// INDEXING PROGRESS
+// ---------------------------------------------
+
+// SpinnerFrames are the frames of the spinner shown while indexing, for
+// front-ends that show their own progress.
+var SpinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// progress reports a running file count while indexing. On a terminal it
+// redraws a single spinner line; otherwise it prints an occasional plain
+// line so logs stay readable.
+type progress struct {
+	out      io.Writer
+	tty      bool
+	start    time.Time
+	interval time.Duration // how often update should be called
+	frame    int
+}
+
+func newProgress(w io.Writer) *progress {
+	p := &progress{out: w, start: time.Now(), interval: 5 * time.Second}
+	if f, ok := w.(*os.File); ok {
+		p.tty = IsTerminal(f)
+	}
+	if w == nil {
+		p.out = io.Discard
+	} else if p.tty {
+		p.interval = 100 * time.Millisecond
+	}
+	return p
+}
+
+// update shows that files have been indexed so far.
+func (p *progress) update(files int) {
+	elapsed := time.Since(p.start).Truncate(100 * time.Millisecond)
+	if !p.tty {
+		fmt.Fprintf(p.out, "Indexed %d files (%v)\n", files, elapsed)
+		return
+	}
+	frame := SpinnerFrames[p.frame%len(SpinnerFrames)]
+	p.frame++
+	fmt.Fprintf(p.out, "\r\033[K%c Indexed %d files (%v)", frame, files, elapsed)
+}
+
+// done clears the spinner line so the summary starts on a clean line.
+func (p *progress) done() {
+	if p.tty && p.frame > 0 {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+// IsTerminal reports whether f is a character device such as a terminal
+// rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
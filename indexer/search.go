@@ -0,0 +1,1184 @@
+package indexer
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------
+// SEARCH & MATCHING
+// ---------------------------------------------
+
+// Mode is how a query is matched against paths.
+type Mode int
+
+const (
+	ModeSubstring Mode = iota
+	ModeFuzzy
+	ModeRegex
+	ModeAcronym
+)
+
+func (s Mode) String() string {
+	switch s {
+	case ModeFuzzy:
+		return "fuzzy"
+	case ModeRegex:
+		return "regex"
+	case ModeAcronym:
+		return "acronym"
+	default:
+		return "substring"
+	}
+}
+
+// ParseMode parses a mode by its String name; "" means ModeSubstring.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "substring":
+		return ModeSubstring, nil
+	case "fuzzy":
+		return ModeFuzzy, nil
+	case "regex":
+		return ModeRegex, nil
+	case "acronym":
+		return ModeAcronym, nil
+	}
+	return ModeSubstring, fmt.Errorf("unknown search mode %q", s)
+}
+
+// SearchOptions selects how Search interprets a query.
+type SearchOptions struct {
+	Mode          Mode
+	CaseSensitive bool
+
+	// Regexp is the compiled query for regex mode. When nil, Search
+	// compiles the query itself.
+	Regexp *regexp.Regexp
+
+	// Limit caps the number of matches returned; 0 returns every match.
+	Limit int
+
+	// NameOnly matches against the base name instead of the full path.
+	NameOnly bool
+
+	// Sort orders the matches, and so decides which survive Limit.
+	Sort SortOrder
+
+	// Roots are the index roots that ^prefix terms are relative to.
+	Roots []string
+
+	// Root, when set, only matches files found under that index root.
+	Root string
+
+	// Trigrams, when set, must index the entries being searched. It lets
+	// substring mode skip entries that can't contain the terms.
+	Trigrams *TrigramIndex
+}
+
+// SortOrder is the order Search returns its matches in.
+type SortOrder int
+
+const (
+	SortRelevance SortOrder = iota // best match first
+	SortModTime                    // most recently modified first
+	SortPath                       // by path, ignoring case
+)
+
+func (s SortOrder) String() string {
+	switch s {
+	case SortModTime:
+		return "newest first"
+	case SortPath:
+		return "path"
+	default:
+		return "relevance"
+	}
+}
+
+// ParseSortOrder parses the name of a sort order: "relevance", "newest"
+// or "path".
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch s {
+	case "", "relevance":
+		return SortRelevance, nil
+	case "newest":
+		return SortModTime, nil
+	case "path":
+		return SortPath, nil
+	}
+	return SortRelevance, fmt.Errorf("unknown sort order %q (want relevance, newest or path)", s)
+}
+
+// CompileRegexp compiles a regex-mode query, honoring case sensitivity.
+func CompileRegexp(query string, caseSensitive bool) (*regexp.Regexp, error) {
+	src := strings.TrimSpace(query)
+	if !caseSensitive {
+		src = "(?i)" + src
+	}
+	return regexp.Compile(src)
+}
+
+// fold normalizes s for comparison: lowercased unless case-sensitive.
+func (o SearchOptions) fold(s string) string {
+	if o.CaseSensitive {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// target returns the part of path that queries are matched against.
+func (o SearchOptions) target(path string) string {
+	if o.NameOnly {
+		return path[baseStart(path):]
+	}
+	return path
+}
+
+// baseStart returns the byte offset at which path's base name begins.
+func baseStart(path string) int {
+	return strings.LastIndexAny(path, `/\`) + 1
+}
+
+// Search returns the positions in entries of the entries matching query,
+// most relevant first, along with the total number of matches before
+// opts.Limit was applied. Positions rather than copies keep the results of
+// a search over millions of files small.
+func Search(entries []FileEntry, query string, opts SearchOptions) ([]int, int) {
+	return SearchContext(context.Background(), entries, query, opts)
+}
+
+// parallelSearchMin is the smallest index split across several workers;
+// below it starting goroutines costs more than it saves.
+const parallelSearchMin = 16384
+
+// scanEntries ranks the entries accepted by match, which reports a score
+// and whether the entry matches at all. Only the entries at positions are
+// scanned, in ascending order, unless positions is nil. Large scans are
+// cut into one contiguous chunk per usable CPU, each ranked by its own
+// worker, and the partial results merged. Ties are broken by index
+// position, so the outcome, cap included, is the same as a sequential
+// scan. match must be safe for concurrent use.
+func scanEntries(ctx context.Context, entries []FileEntry, positions []int32, opts SearchOptions, match func(*FileEntry) (int, bool)) ([]int, int) {
+	n := len(entries)
+	if positions != nil {
+		n = len(positions)
+	}
+	// GOMAXPROCS rather than NumCPU, so a CPU quota is respected
+	workers := runtime.GOMAXPROCS(0)
+	if n < parallelSearchMin {
+		workers = 1
+	}
+	chunk := (n + workers - 1) / max(workers, 1)
+
+	parts := make([]*rankedHits, workers)
+	var wg sync.WaitGroup
+	for w := range parts {
+		hits := newRankedHits(opts)
+		parts[w] = hits
+		lo := min(w*chunk, n)
+		hi := min(lo+chunk, n)
+		wg.Go(func() {
+			for i := lo; i < hi; i++ {
+				if (i-lo)%cancelCheckInterval == 0 && ctx.Err() != nil {
+					return
+				}
+				pos := i
+				if positions != nil {
+					pos = int(positions[i])
+				}
+				if score, ok := match(&entries[pos]); ok {
+					hits.add(&entries[pos], score, pos)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, 0
+	}
+
+	merged := parts[0]
+	for _, p := range parts[1:] {
+		merged.merge(p)
+	}
+	return merged.result()
+}
+
+// cancelCheckInterval is how many entries are scanned between checks for
+// cancellation, keeping the check off the per-entry hot path.
+const cancelCheckInterval = 4096
+
+// SearchContext is Search that gives up and returns nil once ctx is done.
+func SearchContext(ctx context.Context, entries []FileEntry, query string, opts SearchOptions) ([]int, int) {
+	if opts.Mode == ModeRegex {
+		return regexSearch(ctx, entries, query, opts)
+	}
+
+	pq := parseQuery(query, opts)
+	if pq.empty() {
+		return nil, 0
+	}
+
+	switch opts.Mode {
+	case ModeFuzzy:
+		return fuzzySearch(ctx, entries, pq, opts)
+	case ModeAcronym:
+		return acronymSearch(ctx, entries, pq, opts)
+	}
+
+	// Candidates keep their index order, so ties still break the same way
+	var candidates []int32
+	if opts.Trigrams != nil {
+		if positions, ok := opts.Trigrams.candidates(slices.Concat(pq.terms, pq.dirs, pq.names, pq.exact)); ok {
+			candidates = positions
+			if candidates == nil {
+				// An empty list, as nil would scan everything
+				candidates = []int32{}
+			}
+		}
+	}
+	return scanEntries(ctx, entries, candidates, opts, func(file *FileEntry) (int, bool) {
+		lower := opts.fold(opts.target(file.Path))
+		if !pq.filter(file, lower, opts) {
+			return 0, false
+		}
+		for _, term := range pq.terms {
+			if !strings.Contains(lower, term) {
+				return 0, false
+			}
+		}
+		if !pq.matchContent(ctx, file, opts) {
+			return 0, false
+		}
+		return relevanceScore(pq.terms, lower), true
+	})
+}
+
+// parsedQuery is a query split into the plain terms every match must
+// contain and the operator filters applied before term matching.
+type parsedQuery struct {
+	// terms are case-folded the same way as the paths they are compared to.
+	terms []string
+	// excludes are case-folded terms no match may contain.
+	excludes []string
+	// exts are lowercased extensions including the dot; any may match.
+	exts []string
+	// dirs and names are case-folded terms the directory part and the base
+	// name of a match must contain respectively.
+	dirs  []string
+	names []string
+	// exact are case-folded base names a match must equal.
+	exact []string
+	// prefixes are case-folded, slash-separated paths relative to a root
+	// that a match must start with.
+	prefixes []string
+	// sizes are bounds every match's size must satisfy.
+	sizes []sizeFilter
+	// contents are case-folded terms the file itself must contain.
+	contents []string
+}
+
+// sizeFilter is a size:>n or size:<n bound, in bytes.
+type sizeFilter struct {
+	larger bool
+	bytes  int64
+}
+
+// parseSizeFilter parses the ">10M" of size:>10M, the size as ParseSize
+// takes it.
+func parseSizeFilter(s string) (sizeFilter, bool) {
+	var f sizeFilter
+	switch {
+	case strings.HasPrefix(s, ">"):
+		f.larger = true
+	case strings.HasPrefix(s, "<"):
+	default:
+		return f, false
+	}
+	bytes, err := ParseSize(s[1:])
+	if err != nil {
+		return f, false
+	}
+	f.bytes = bytes
+	return f, true
+}
+
+// ParseSize parses a size in bytes such as "512", "10k" or "1.5G". The
+// optional k, M or G suffix, in either case, counts in powers of 1024.
+func ParseSize(s string) (int64, error) {
+	num, mult := s, int64(1)
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q: want e.g. 512, 10k, 100M or 2G", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// allows reports whether a file of size bytes satisfies f.
+func (f sizeFilter) allows(size int64) bool {
+	if f.larger {
+		return size > f.bytes
+	}
+	return size < f.bytes
+}
+
+// parseQuery splits query on whitespace, keeping double-quoted spans such
+// as "new folder" together, and pulls out operator terms:
+//
+//	-test    exclude paths containing "test"
+//	ext:go   only files with extension .go (repeat to allow several)
+//	dir:src  only files whose directory contains "src"
+//	name:foo only files whose base name contains "foo"
+//	=main.go only files named exactly main.go
+//	^src/    only files whose path below their root starts with src/
+//	size:>1M only files larger than 1 MiB; also size:<10k, suffixes k/M/G
+//	content:x only text files containing "x", see containsAll
+//
+// A field opening with a quote is always a plain term, so "-draft" finds
+// paths containing -draft, while operators take quoted values as in
+// name:"new folder".
+func parseQuery(query string, opts SearchOptions) parsedQuery {
+	var pq parsedQuery
+	for _, qf := range splitQuery(query) {
+		field := qf.text
+		if field == "" {
+			continue
+		}
+		if qf.literal {
+			pq.terms = append(pq.terms, opts.fold(field))
+			continue
+		}
+		if rest, ok := strings.CutPrefix(field, "-"); ok {
+			// A bare "-" is ignored rather than excluding everything
+			if rest != "" {
+				pq.excludes = append(pq.excludes, opts.fold(rest))
+			}
+			continue
+		}
+		if rest, ok := cutPrefixFold(field, "ext:"); ok {
+			if rest = strings.TrimPrefix(rest, "."); rest != "" {
+				pq.exts = append(pq.exts, "."+strings.ToLower(rest))
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(field, "="); ok {
+			if rest != "" {
+				pq.exact = append(pq.exact, opts.fold(rest))
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(field, "^"); ok {
+			if rest != "" {
+				pq.prefixes = append(pq.prefixes, opts.fold(rest))
+			}
+			continue
+		}
+		if rest, ok := cutPrefixFold(field, "size:"); ok {
+			// Incomplete while being typed, such as "size:>", is ignored
+			if f, ok := parseSizeFilter(rest); ok {
+				pq.sizes = append(pq.sizes, f)
+			}
+			continue
+		}
+		if rest, ok := cutPrefixFold(field, "dir:"); ok {
+			if rest != "" {
+				pq.dirs = append(pq.dirs, opts.fold(rest))
+			}
+			continue
+		}
+		if rest, ok := cutPrefixFold(field, "name:"); ok {
+			if rest != "" {
+				pq.names = append(pq.names, opts.fold(rest))
+			}
+			continue
+		}
+		if rest, ok := cutPrefixFold(field, "content:"); ok {
+			if rest != "" {
+				pq.contents = append(pq.contents, opts.fold(rest))
+			}
+			continue
+		}
+		pq.terms = append(pq.terms, opts.fold(field))
+	}
+	return pq
+}
+
+// queryField is one field of a query with its quotes removed. literal is
+// set when it opened with a quote.
+type queryField struct {
+	text    string
+	literal bool
+}
+
+// splitQuery splits query into fields at whitespace outside double quotes.
+// A quote left open, as while one is being typed, runs to the end.
+func splitQuery(query string) []queryField {
+	var fields []queryField
+	var b strings.Builder
+	var cur queryField
+	inQuote, started := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			if !started {
+				cur.literal = true
+			}
+			inQuote, started = !inQuote, true
+		case !inQuote && unicode.IsSpace(r):
+			if started {
+				cur.text = b.String()
+				fields = append(fields, cur)
+				b.Reset()
+				cur, started = queryField{}, false
+			}
+		default:
+			b.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		cur.text = b.String()
+		fields = append(fields, cur)
+	}
+	return fields
+}
+
+// cutPrefixFold is strings.CutPrefix with an ASCII case-insensitive prefix.
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// empty reports whether the query has nothing to match on.
+func (pq parsedQuery) empty() bool {
+	return len(pq.terms) == 0 && len(pq.excludes) == 0 && len(pq.exts) == 0 &&
+		len(pq.dirs) == 0 && len(pq.names) == 0 && len(pq.exact) == 0 && len(pq.prefixes) == 0 &&
+		len(pq.sizes) == 0 && len(pq.contents) == 0
+}
+
+// filter reports whether e, whose case-folded path is folded, passes the
+// query's operator filters. folded is only used for excludes, so dir: and
+// name: terms see the full path even when matching names only.
+func (pq parsedQuery) filter(e *FileEntry, folded string, opts SearchOptions) bool {
+	if opts.Root != "" && e.Root != opts.Root {
+		return false
+	}
+	for _, ex := range pq.excludes {
+		if strings.Contains(folded, ex) {
+			return false
+		}
+	}
+	if len(pq.exts) > 0 {
+		ext := strings.ToLower(filepath.Ext(e.Path))
+		if !slices.Contains(pq.exts, ext) {
+			return false
+		}
+	}
+	if len(pq.dirs) > 0 {
+		dir := opts.fold(filepath.Dir(e.Path))
+		for _, t := range pq.dirs {
+			if !strings.Contains(dir, t) {
+				return false
+			}
+		}
+	}
+	if len(pq.names) > 0 || len(pq.exact) > 0 {
+		name := opts.fold(filepath.Base(e.Path))
+		for _, t := range pq.names {
+			if !strings.Contains(name, t) {
+				return false
+			}
+		}
+		for _, t := range pq.exact {
+			if name != t {
+				return false
+			}
+		}
+	}
+	for _, f := range pq.sizes {
+		// Entries from legacy indexes have no size to compare
+		if e.ModTime.IsZero() || !f.allows(e.Size) {
+			return false
+		}
+	}
+	if len(pq.prefixes) > 0 {
+		rel := opts.fold(relToRoot(opts.Roots, e.Path))
+		for _, p := range pq.prefixes {
+			if !strings.HasPrefix(rel, p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// relToRoot returns path relative to the first of roots containing it,
+// with forward slashes, or path itself when no root does.
+func relToRoot(roots []string, path string) string {
+	for _, root := range roots {
+		if IsWithin(root, path) && path != root {
+			rel, err := filepath.Rel(root, path)
+			if err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// QueryTerms returns the plain terms of query, as used for highlighting.
+func QueryTerms(query string, opts SearchOptions) []string {
+	return parseQuery(query, opts).terms
+}
+
+// SmartCase reports whether smart case matches query case-sensitively:
+// whether a plain term or name: value has an upper-case letter. Operators
+// and their other values, as in size:>1M or ext:MD, don't count. A regex is
+// a single pattern, in which only letters escaped by a backslash, such as
+// \W and \D, don't count.
+func SmartCase(query string, mode Mode) bool {
+	if mode == ModeRegex {
+		escaped := false
+		for _, r := range query {
+			if unicode.IsUpper(r) && !escaped {
+				return true
+			}
+			escaped = r == '\\' && !escaped
+		}
+		return false
+	}
+	// Unfolded, so the terms keep the case they were typed in
+	pq := parseQuery(query, SearchOptions{CaseSensitive: true})
+	return slices.ContainsFunc(slices.Concat(pq.terms, pq.names), func(term string) bool {
+		return strings.IndexFunc(term, unicode.IsUpper) >= 0
+	})
+}
+
+// scoredEntry pairs a matched entry with its ranking score and its
+// position in the index, which breaks remaining ties.
+type scoredEntry struct {
+	entry *FileEntry
+	score int
+	seq   int
+}
+
+// ranksBefore orders by descending score, then shorter path, then walk order.
+func (a scoredEntry) ranksBefore(b scoredEntry) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if len(a.entry.Path) != len(b.entry.Path) {
+		return len(a.entry.Path) < len(b.entry.Path)
+	}
+	return a.seq < b.seq
+}
+
+// rankedHits collects scored matches. With a limit it keeps only the best
+// limit hits in a heap whose root is the worst kept hit, so memory stays
+// bounded however many paths match while the survivors are still the top
+// of the whole matched set.
+type rankedHits struct {
+	limit int
+	order SortOrder
+	hits  []scoredEntry
+	total int
+}
+
+func newRankedHits(opts SearchOptions) *rankedHits {
+	return &rankedHits{limit: opts.Limit, order: opts.Sort}
+}
+
+// before orders hits by the requested sort, falling back to relevance.
+func (r *rankedHits) before(a, b scoredEntry) bool {
+	switch r.order {
+	case SortModTime:
+		if !a.entry.ModTime.Equal(b.entry.ModTime) {
+			return a.entry.ModTime.After(b.entry.ModTime)
+		}
+	case SortPath:
+		if c := comparePathFold(a.entry.Path, b.entry.Path); c != 0 {
+			return c < 0
+		}
+		return a.seq < b.seq
+	}
+	return a.ranksBefore(b)
+}
+
+// comparePathFold compares a and b ignoring case, falling back to a
+// case-sensitive comparison for paths that only differ in case.
+func comparePathFold(a, b string) int {
+	x, y := a, b
+	for x != "" && y != "" {
+		rx, nx := utf8.DecodeRuneInString(x)
+		ry, ny := utf8.DecodeRuneInString(y)
+		if lx, ly := unicode.ToLower(rx), unicode.ToLower(ry); lx != ly {
+			return cmp.Compare(lx, ly)
+		}
+		x, y = x[nx:], y[ny:]
+	}
+	// The shorter is a prefix of the other
+	if c := cmp.Compare(len(x), len(y)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func (r *rankedHits) Len() int           { return len(r.hits) }
+func (r *rankedHits) Less(i, j int) bool { return r.before(r.hits[j], r.hits[i]) }
+func (r *rankedHits) Swap(i, j int)      { r.hits[i], r.hits[j] = r.hits[j], r.hits[i] }
+func (r *rankedHits) Push(x any)         { r.hits = append(r.hits, x.(scoredEntry)) }
+func (r *rankedHits) Pop() any {
+	last := r.hits[len(r.hits)-1]
+	r.hits = r.hits[:len(r.hits)-1]
+	return last
+}
+
+// add records a match of e, the seq'th entry of the index.
+func (r *rankedHits) add(e *FileEntry, score, seq int) {
+	r.total++
+	r.keep(scoredEntry{entry: e, score: score, seq: seq})
+}
+
+// merge adds every hit kept by o, which ranked a disjoint set of entries.
+// Because o kept its own top hits, the result equals ranking both sets
+// together.
+func (r *rankedHits) merge(o *rankedHits) {
+	r.total += o.total
+	for _, h := range o.hits {
+		r.keep(h)
+	}
+}
+
+func (r *rankedHits) keep(h scoredEntry) {
+	switch {
+	case r.limit <= 0:
+		r.hits = append(r.hits, h)
+	case len(r.hits) < r.limit:
+		// Appending rather than heap.Push, which boxes every hit; the
+		// heap is only needed once it's full
+		r.hits = append(r.hits, h)
+		if len(r.hits) == r.limit {
+			heap.Init(r)
+		}
+	case r.before(h, r.hits[0]):
+		r.hits[0] = h
+		heap.Fix(r, 0)
+	}
+}
+
+// result returns the positions of the kept hits in sort order and the
+// total match count.
+func (r *rankedHits) result() ([]int, int) {
+	sort.Slice(r.hits, func(i, j int) bool { return r.before(r.hits[i], r.hits[j]) })
+	matches := make([]int, len(r.hits))
+	for i, h := range r.hits {
+		matches[i] = h.seq
+	}
+	return matches, r.total
+}
+
+// relevanceScore ranks a path against terms that are all known to occur in
+// it, both already case-folded. A term equal to the base name (with or without
+// its extension) scores highest, then a prefix of the base name, then any
+// hit inside the base name; terms found only in the directory score least.
+func relevanceScore(terms []string, path string) int {
+	base := path[strings.LastIndexAny(path, `/\`)+1:]
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	score := 0
+	for _, term := range terms {
+		switch {
+		case term == base || term == stem:
+			score += 100
+		case strings.HasPrefix(base, term):
+			score += 60
+		case strings.Contains(base, term):
+			score += 40
+		default:
+			score += 10
+		}
+	}
+	return score
+}
+
+func fuzzySearch(ctx context.Context, entries []FileEntry, pq parsedQuery, opts SearchOptions) ([]int, int) {
+	return scanEntries(ctx, entries, nil, opts, func(file *FileEntry) (int, bool) {
+		lower := opts.fold(opts.target(file.Path))
+		if !pq.filter(file, lower, opts) {
+			return 0, false
+		}
+		total := 0
+		for _, term := range pq.terms {
+			score, _, ok := fuzzyMatch(term, lower)
+			if !ok {
+				return 0, false
+			}
+			total += score
+		}
+		if !pq.matchContent(ctx, file, opts) {
+			return 0, false
+		}
+		return total, true
+	})
+}
+
+// acronymSearch matches every term against the base name with
+// acronymMatch, so "hc" finds HttpClient.go and http_client.go.
+func acronymSearch(ctx context.Context, entries []FileEntry, pq parsedQuery, opts SearchOptions) ([]int, int) {
+	return scanEntries(ctx, entries, nil, opts, func(file *FileEntry) (int, bool) {
+		if !pq.filter(file, opts.fold(opts.target(file.Path)), opts) {
+			return 0, false
+		}
+		base := file.Path[baseStart(file.Path):]
+		total := 0
+		for _, term := range pq.terms {
+			score, _, ok := acronymMatch(term, base, opts.CaseSensitive)
+			if !ok {
+				return 0, false
+			}
+			total += score
+		}
+		if !pq.matchContent(ctx, file, opts) {
+			return 0, false
+		}
+		return total, true
+	})
+}
+
+// regexSearch matches the whole query as a regular expression against each
+// path, ranking paths whose first match falls in the base name higher.
+func regexSearch(ctx context.Context, entries []FileEntry, query string, opts SearchOptions) ([]int, int) {
+	if strings.TrimSpace(query) == "" {
+		return nil, 0
+	}
+	re := opts.Regexp
+	if re == nil {
+		var err error
+		if re, err = CompileRegexp(query, opts.CaseSensitive); err != nil {
+			return nil, 0
+		}
+	}
+
+	// A Regexp is safe for concurrent use by the scan's workers
+	return scanEntries(ctx, entries, nil, opts, func(file *FileEntry) (int, bool) {
+		if opts.Root != "" && file.Root != opts.Root {
+			return 0, false
+		}
+		target := opts.target(file.Path)
+		loc := re.FindStringIndex(target)
+		if loc == nil {
+			return 0, false
+		}
+		if loc[0] >= baseStart(target) {
+			return 1, true
+		}
+		return 0, true
+	})
+}
+
+// Fuzzy scoring, in the spirit of fzf: every matched character earns
+// fuzzyMatchScore plus bonuses, and each gap between matches costs
+// fuzzyGapStart for its first skipped character and fuzzyGapExtension for
+// each further one.
+const (
+	fuzzyMatchScore       = 16
+	fuzzyGapStart         = -3
+	fuzzyGapExtension     = -1
+	fuzzyBoundaryBonus    = 8 // the character starts a word or path segment
+	fuzzyConsecutiveBonus = 4 // the character directly follows the previous match
+	fuzzyBaseBonus        = 2 // the character is in the base name
+)
+
+// fuzzyMatch reports whether the runes of term appear in target in order,
+// not necessarily adjacent, and scores the match. Every way of picking the
+// runes is considered and the best scoring one kept, so scattered
+// characters early on don't outscore a tight cluster or a base name hit
+// later in the path. A boundary bonus on the first character counts
+// double, and ties go to the match that starts earlier. It returns the
+// byte offsets of the matched runes.
+func fuzzyMatch(term, target string) (int, []int, bool) {
+	q := []rune(term)
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+	// Most paths don't match at all, which is cheap to find out first
+	qi := 0
+	for _, r := range target {
+		if qi < len(q) && r == q[qi] {
+			qi++
+		}
+	}
+	if qi < len(q) {
+		return 0, nil, false
+	}
+
+	runes := make([]rune, 0, len(target))
+	offsets := make([]int, 0, len(target))
+	for off, r := range target {
+		runes = append(runes, r)
+		offsets = append(offsets, off)
+	}
+	base := 0
+	for i, r := range runes {
+		if r == '/' || r == '\\' {
+			base = i + 1
+		}
+	}
+	// bonus is what matching runes[i] earns apart from fuzzyMatchScore,
+	// with a bonus for directly following the previous match if next
+	bonus := func(i int, next bool) int {
+		b := 0
+		if i == 0 || isWordSeparator(runes[i-1]) {
+			b = fuzzyBoundaryBonus
+		}
+		if next {
+			b = max(b, fuzzyConsecutiveBonus)
+		}
+		if i >= base {
+			b += fuzzyBaseBonus
+		}
+		return b
+	}
+
+	// q[j] can only be matched from where the earliest match puts it up
+	// to where the latest does
+	n := len(runes)
+	// One allocation for every table below
+	cells := make([]int, 2*len(q)+2*len(q)*n)
+	lo, hi := cells[:len(q)], cells[len(q):2*len(q)]
+	for i, j := 0, 0; j < len(q); i++ {
+		if runes[i] == q[j] {
+			lo[j] = i
+			j++
+		}
+	}
+	for i, j := n-1, len(q)-1; j >= 0; i-- {
+		if runes[i] == q[j] {
+			hi[j] = i
+			j--
+		}
+	}
+
+	// best[j*n+i] is the highest score of matching q[:j+1] with q[j] on
+	// runes[i], or noMatch, and from[j*n+i] the rune q[j-1] is on then.
+	// Only the cells from lo[j] to hi[j] are read. Scores are kept times
+	// 32 less the start, capped at 31, which is constant along a match
+	// and so breaks ties as promised.
+	const noMatch = math.MinInt
+	best, from := cells[2*len(q):2*len(q)+len(q)*n], cells[2*len(q)+len(q)*n:]
+	for i := lo[0]; i <= hi[0]; i++ {
+		best[i] = noMatch
+		if runes[i] == q[0] {
+			b := 0
+			if i == 0 || isWordSeparator(runes[i-1]) {
+				b = 2 * fuzzyBoundaryBonus
+			}
+			if i >= base {
+				b += fuzzyBaseBonus
+			}
+			best[i] = (fuzzyMatchScore+b)*32 - min(i, 31)
+		}
+	}
+	for j := 1; j < len(q); j++ {
+		prev, cur := best[(j-1)*n:j*n], best[j*n:(j+1)*n]
+		// gap is the best score of a match of q[:j] that ended before
+		// runes[i-1], charged for the gap up to runes[i], from where it ended
+		gap, gapFrom := noMatch, 0
+		// From right after the first place q[j-1] can be, for the gaps
+		for i := lo[j-1] + 1; i <= hi[j]; i++ {
+			if gap != noMatch {
+				gap += fuzzyGapExtension * 32
+			}
+			if k := i - 2; k >= lo[j-1] && k <= hi[j-1] && prev[k] != noMatch && prev[k]+fuzzyGapStart*32 > gap {
+				gap, gapFrom = prev[k]+fuzzyGapStart*32, k
+			}
+			cur[i] = noMatch
+			if runes[i] != q[j] {
+				continue
+			}
+			if i-1 <= hi[j-1] && prev[i-1] != noMatch {
+				cur[i], from[j*n+i] = prev[i-1]+(fuzzyMatchScore+bonus(i, true))*32, i-1
+			}
+			if gap != noMatch && gap+(fuzzyMatchScore+bonus(i, false))*32 > cur[i] {
+				cur[i], from[j*n+i] = gap+(fuzzyMatchScore+bonus(i, false))*32, gapFrom
+			}
+		}
+	}
+
+	last := len(q) - 1
+	end := -1
+	for i := lo[last]; i <= hi[last]; i++ {
+		if score := best[last*n+i]; score != noMatch && (end < 0 || score > best[last*n+end]) {
+			end = i
+		}
+	}
+	positions := make([]int, len(q))
+	for j, i := last, end; j >= 0; j-- {
+		positions[j] = offsets[i]
+		i = from[j*n+i]
+	}
+	return best[last*n+end], positions, true
+}
+
+// isWordSeparator reports whether r ends a word or path segment.
+func isWordSeparator(r rune) bool {
+	switch r {
+	case '/', '\\', '_', '-', '.', ' ':
+		return true
+	}
+	return false
+}
+
+// acronymInitialsBonus lifts every match made purely of word initials
+// above any match that needs mid-word characters.
+const acronymInitialsBonus = 1000
+
+// acronymMatch matches the runes of term, already case-folded, against the
+// initials of name's word segments (see wordStarts) in order. Failing that
+// it accepts any in-order match, scoring characters at word starts higher
+// than mid-word ones. It returns the byte offsets of the matched runes.
+func acronymMatch(term, name string, caseSensitive bool) (int, []int, bool) {
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+	runes := []rune(name)
+	starts := wordStarts(runes)
+	offsets := make([]int, len(runes))
+	for i, off := 0, 0; i < len(runes); i++ {
+		offsets[i] = off
+		off += utf8.RuneLen(runes[i])
+	}
+	q := []rune(term)
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+
+	// Initials only: reward consecutive segments and starting at the first
+	var hits []int
+	seg, lastSeg, score := -1, -2, acronymInitialsBonus
+	for i, r := range runes {
+		if !starts[i] {
+			continue
+		}
+		seg++
+		if len(hits) < len(q) && fold(r) == q[len(hits)] {
+			if seg == 0 {
+				score += 20
+			}
+			if seg == lastSeg+1 {
+				score += 10
+			}
+			hits = append(hits, offsets[i])
+			lastSeg = seg
+		}
+	}
+	if len(hits) == len(q) {
+		return score, hits, true
+	}
+
+	hits, score = hits[:0], 0
+	for i, r := range runes {
+		if len(hits) < len(q) && fold(r) == q[len(hits)] {
+			score++
+			if starts[i] {
+				score += 2
+			}
+			hits = append(hits, offsets[i])
+		}
+	}
+	if len(hits) < len(q) {
+		return 0, nil, false
+	}
+	return score, hits, true
+}
+
+// wordStarts marks the runes that begin a word segment: the first letter
+// or digit after a separator, an upper-case letter after a lower-case one,
+// the last capital of a run followed by lower case ("HTTPServer" splits
+// as HTTP Server), and the switch between letters and digits.
+func wordStarts(runes []rune) []bool {
+	starts := make([]bool, len(runes))
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for i, r := range runes {
+		if !isWord(r) {
+			continue
+		}
+		if i == 0 || !isWord(runes[i-1]) {
+			starts[i] = true
+			continue
+		}
+		prev := runes[i-1]
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			starts[i] = true
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			starts[i] = true
+		case unicode.IsDigit(r) != unicode.IsDigit(prev):
+			starts[i] = true
+		}
+	}
+	return starts
+}
+
+// Range is a half-open byte range [start, end) of a path.
+type Range struct {
+	Start, End int
+}
+
+// MatchRanges locates where terms hit path under opts, returning sorted
+// ranges with overlapping and adjacent hits merged. Substring mode reports
+// every occurrence of every term; fuzzy mode reports the characters picked
+// by the subsequence match.
+func MatchRanges(path string, terms []string, opts SearchOptions) []Range {
+	if opts.NameOnly {
+		off := baseStart(path)
+		opts.NameOnly = false
+		ranges := MatchRanges(path[off:], terms, opts)
+		for i := range ranges {
+			ranges[i].Start += off
+			ranges[i].End += off
+		}
+		return ranges
+	}
+	if opts.Mode == ModeRegex {
+		if opts.Regexp == nil {
+			return nil
+		}
+		var ranges []Range
+		for _, loc := range opts.Regexp.FindAllStringIndex(path, -1) {
+			if loc[0] < loc[1] {
+				ranges = append(ranges, Range{loc[0], loc[1]})
+			}
+		}
+		return ranges
+	}
+
+	if opts.Mode == ModeAcronym {
+		// Acronyms always match the base name
+		off := baseStart(path)
+		var ranges []Range
+		for _, term := range terms {
+			_, hits, _ := acronymMatch(term, path[off:], opts.CaseSensitive)
+			for _, h := range hits {
+				_, size := utf8.DecodeRuneInString(path[off+h:])
+				ranges = append(ranges, Range{off + h, off + h + size})
+			}
+		}
+		return mergeRanges(ranges)
+	}
+
+	lower, offsets := path, []int(nil)
+	if !opts.CaseSensitive {
+		lower, offsets = lowerWithOffsets(path)
+	}
+
+	var ranges []Range
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		if opts.Mode == ModeFuzzy {
+			_, hits, _ := fuzzyMatch(term, lower)
+			for _, h := range hits {
+				_, size := utf8.DecodeRuneInString(lower[h:])
+				ranges = append(ranges, Range{h, h + size})
+			}
+			continue
+		}
+		for from := 0; ; {
+			i := strings.Index(lower[from:], term)
+			if i < 0 {
+				break
+			}
+			ranges = append(ranges, Range{from + i, from + i + len(term)})
+			from += i + 1
+		}
+	}
+
+	// Translate offsets in the lowercased string back to the original path
+	if offsets != nil {
+		for i := range ranges {
+			ranges[i] = Range{offsets[ranges[i].Start], offsets[ranges[i].End]}
+		}
+	}
+	return mergeRanges(ranges)
+}
+
+// lowerWithOffsets lowercases s rune by rune and returns, for every byte of
+// the result plus one past the end, the corresponding byte offset in s. This
+// keeps hit positions valid even when lowercasing changes a rune's width.
+func lowerWithOffsets(s string) (string, []int) {
+	var sb strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		n, _ := sb.WriteRune(unicode.ToLower(r))
+		for range n {
+			offsets = append(offsets, i)
+		}
+	}
+	offsets = append(offsets, len(s))
+	return sb.String(), offsets
+}
+
+func mergeRanges(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Recent returns the positions of the n most recently modified
+// entries, newest first. Entries without a modification time, from legacy
+// indexes, are left out.
+func Recent(entries []FileEntry, n int) []int {
+	hits := newRankedHits(SearchOptions{Limit: n, Sort: SortModTime})
+	for i := range entries {
+		if !entries[i].ModTime.IsZero() {
+			hits.add(&entries[i], 0, i)
+		}
+	}
+	matches, _ := hits.result()
+	return matches
+}
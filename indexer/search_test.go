@@ -0,0 +1,191 @@
+package indexer
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// paths returns the entries for ps, in order.
+func paths(ps ...string) []FileEntry {
+	entries := make([]FileEntry, len(ps))
+	for i, p := range ps {
+		entries[i] = FileEntry{Path: p}
+	}
+	return entries
+}
+
+// searchPaths runs query over entries and returns the matched paths in
+// ranked order.
+func searchPaths(entries []FileEntry, query string, opts SearchOptions) []string {
+	matches, _ := Search(entries, query, opts)
+	var got []string
+	for _, i := range matches {
+		got = append(got, entries[i].Path)
+	}
+	return got
+}
+
+func TestRelevanceOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		paths []string // in the expected order, indexed in reverse
+	}{
+		{"exact base name first", "main", []string{"/src/main", "/src/mainframe.c", "/src/domain.c", "/main/readme"}},
+		{"stem counts as exact", "main", []string{"/src/main.go", "/src/main_test.go"}},
+		{"prefix before substring", "conf", []string{"/etc/config.json", "/etc/myconf.json"}},
+		{"substring before directory", "log", []string{"/var/catalog.txt", "/var/log/messages"}},
+		{"shorter path breaks ties", "util", []string{"/a/util.go", "/a/b/c/util.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reversed, so index order can't produce the expected ranking
+			entries := paths(tt.paths...)
+			slices.Reverse(entries)
+			got := searchPaths(entries, tt.query, SearchOptions{})
+			if !slices.Equal(got, tt.paths) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.paths)
+			}
+		})
+	}
+}
+
+// syntheticEntries returns n entries spread over nested directories, like
+// a large home directory.
+func syntheticEntries(n int) []FileEntry {
+	exts := []string{".go", ".md", ".txt", ".json", ".png"}
+	entries := make([]FileEntry, n)
+	for i := range entries {
+		entries[i] = FileEntry{Path: fmt.Sprintf("/home/user/project%03d/src/pkg%02d/file%06d%s", i%997, i%53, i, exts[i%len(exts)])}
+	}
+	return entries
+}
+
+func BenchmarkSearch(b *testing.B) {
+	entries := syntheticEntries(1_000_000)
+	for _, mode := range []Mode{ModeSubstring, ModeFuzzy} {
+		for _, bench := range []struct {
+			name  string
+			procs int
+		}{
+			{"sequential", 1},
+			{"parallel", runtime.NumCPU()},
+		} {
+			b.Run(mode.String()+"/"+bench.name, func(b *testing.B) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(bench.procs))
+				opts := SearchOptions{Mode: mode, Limit: 1000}
+				for b.Loop() {
+					Search(entries, "pkg07 file12", opts)
+				}
+			})
+		}
+	}
+}
+
+func TestFuzzyMatchPositions(t *testing.T) {
+	tests := []struct {
+		term, target string
+		positions    []int
+	}{
+		// The contiguous base name, not the scattered letters before it
+		{"abc", "a/b/c/abc", []int{6, 7, 8}},
+		{"main", "/m/a/i/n/src/main.go", []int{13, 14, 15, 16}},
+		{"fb", "/foo/bar/fb", []int{9, 10}},
+		{"\u00e9", "/caf\u00e9", []int{4}},
+	}
+	for _, tt := range tests {
+		_, got, ok := fuzzyMatch(tt.term, tt.target)
+		if !ok || !slices.Equal(got, tt.positions) {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, %v, want %v", tt.term, tt.target, got, ok, tt.positions)
+		}
+	}
+	if _, _, ok := fuzzyMatch("abd", "a/b/c"); ok {
+		t.Errorf("fuzzyMatch(%q, %q) matched", "abd", "a/b/c")
+	}
+}
+
+func TestFuzzyOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		paths []string // in the expected order, indexed in reverse
+	}{
+		{"contiguous beats scattered", "abc", []string{"/x/abc", "/a/b/c/x"}},
+		{"cluster later beats scatter earlier", "report", []string{"/r/e/p/o/r/t/x/report.pdf", "/r/e/p/o/r/t/x/y.pdf"}},
+		{"base name beats directory", "main", []string{"/src/main.go", "/main/readme"}},
+		{"word boundary beats mid-word", "cfg", []string{"/etc/c_f_g", "/etc/xcxfxg"}},
+		{"earlier start breaks ties", "log", []string{"/log/a", "/xy/log/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := paths(tt.paths...)
+			slices.Reverse(entries)
+			got := searchPaths(entries, tt.query, SearchOptions{Mode: ModeFuzzy})
+			if !slices.Equal(got, tt.paths) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.paths)
+			}
+		})
+	}
+}
+
+// BenchmarkSearchAllocs reports the allocations of a search at two index
+// sizes; matches are positions into the entries, so the count shouldn't
+// grow with the index or with how many entries match.
+func BenchmarkSearchAllocs(b *testing.B) {
+	for _, n := range []int{1_000, 1_000_000} {
+		entries := syntheticEntries(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			opts := SearchOptions{Limit: 1000}
+			for b.Loop() {
+				Search(entries, "file", opts)
+			}
+		})
+	}
+}
+
+func TestSmartCase(t *testing.T) {
+	tests := []struct {
+		query string
+		mode  Mode
+		want  bool
+	}{
+		{"readme", ModeSubstring, false},
+		{"README", ModeSubstring, true},
+		{"readme size:>1M", ModeSubstring, false},
+		{"readme Size:<10K", ModeSubstring, false},
+		{"ext:MD readme", ModeSubstring, false},
+		{"dir:Src main", ModeSubstring, false},
+		{"=Main.go", ModeSubstring, false},
+		{"^Src/ main", ModeSubstring, false},
+		{"content:TODO main", ModeSubstring, false},
+		{"-Draft report", ModeSubstring, false},
+		{"name:README", ModeSubstring, true},
+		{`"New folder"`, ModeSubstring, true},
+		{"Mfc", ModeFuzzy, true},
+		{`\W+\.go`, ModeRegex, false},
+		{`\\W`, ModeRegex, true},
+		{`[A-Z]\.go`, ModeRegex, true},
+	}
+	for _, tt := range tests {
+		if got := SmartCase(tt.query, tt.mode); got != tt.want {
+			t.Errorf("SmartCase(%q, %v) = %v, want %v", tt.query, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSmartCaseSizeOperator(t *testing.T) {
+	now := time.Now()
+	entries := []FileEntry{
+		{Path: "/docs/README.md", Size: 2 << 20, ModTime: now},
+		{Path: "/docs/readme.txt", Size: 10, ModTime: now},
+	}
+	query := "readme size:>1M"
+	got := searchPaths(entries, query, SearchOptions{CaseSensitive: SmartCase(query, ModeSubstring)})
+	if want := []string{"/docs/README.md"}; !slices.Equal(got, want) {
+		t.Errorf("Search(%q) = %q, want %q", query, got, want)
+	}
+}
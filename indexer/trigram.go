@@ -0,0 +1,88 @@
+package indexer
+
+import (
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------
+// TRIGRAM INDEX
+// ---------------------------------------------
+
+// TrigramIndex maps every three-byte sequence of the lowercased paths to
+// the positions of the entries containing it, in ascending order. A path
+// can only contain a term if it contains all of the term's trigrams, so
+// intersecting their lists narrows a substring search to a few candidates.
+type TrigramIndex struct {
+	postings map[uint32][]int32
+}
+
+func trigramKey(s string, i int) uint32 {
+	return uint32(s[i])<<16 | uint32(s[i+1])<<8 | uint32(s[i+2])
+}
+
+// NewTrigramIndex indexes entries. Paths are lowercased, which keeps the
+// candidates a superset of the matches for case-sensitive queries too.
+func NewTrigramIndex(entries []FileEntry) *TrigramIndex {
+	t := &TrigramIndex{postings: make(map[uint32][]int32)}
+	for i, e := range entries {
+		path := strings.ToLower(e.Path)
+		for j := 0; j+3 <= len(path); j++ {
+			key := trigramKey(path, j)
+			list := t.postings[key]
+			// Entries are added in order, so a repeat within this path
+			// can only be the last element
+			if n := len(list); n > 0 && list[n-1] == int32(i) {
+				continue
+			}
+			t.postings[key] = append(list, int32(i))
+		}
+	}
+	return t
+}
+
+// candidates returns the positions of the entries that may contain every
+// term, or false when no term is long enough to narrow the search and a
+// full scan is needed.
+func (t *TrigramIndex) candidates(terms []string) ([]int32, bool) {
+	var lists [][]int32
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		for j := 0; j+3 <= len(term); j++ {
+			lists = append(lists, t.postings[trigramKey(term, j)])
+		}
+	}
+	if len(lists) == 0 {
+		return nil, false
+	}
+
+	// Shortest first keeps every intersection step small
+	slices.SortFunc(lists, func(a, b []int32) int { return len(a) - len(b) })
+	result := slices.Clone(lists[0])
+	for _, list := range lists[1:] {
+		if len(result) == 0 {
+			break
+		}
+		result = intersectSorted(result, list)
+	}
+	return result, true
+}
+
+// intersectSorted keeps the elements of a that are also in b, reusing a.
+func intersectSorted(a, b []int32) []int32 {
+	out := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
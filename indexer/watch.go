@@ -0,0 +1,305 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ---------------------------------------------
+// WATCH MODE
+// ---------------------------------------------
+
+const (
+	// watchDebounce is how long events must stop arriving before a burst
+	// of them is applied to the index.
+	watchDebounce = 500 * time.Millisecond
+	// watchMaxPending applies a burst early once this many paths are
+	// waiting, so a constant stream of changes can't defer updates forever.
+	watchMaxPending = 10000
+	// DefaultFlushInterval is how often a changed index is written to disk.
+	DefaultFlushInterval = 30 * time.Second
+)
+
+// Watch builds the index for roots, then keeps it up to date from file
+// system events until ctx is done, saving it to savePath every flushEvery
+// when it has changed and once more on exit. Interrupted during the first
+// build, it saves the partial index and returns.
+func Watch(ctx context.Context, savePath string, roots []string, opts Options, flushEvery time.Duration) error {
+	idx, err := BuildContext(ctx, roots, opts)
+	if err != nil {
+		return err
+	}
+	// Only this first build can reuse it
+	opts.Previous = nil
+	if err := Save(savePath, idx); err != nil || idx.Partial {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot start watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	live := newLiveIndex(idx, opts, watcher)
+	for dir := range idx.Dirs {
+		live.watch(dir)
+	}
+	opts.printf("Watching %d directories, press Ctrl+C to stop.\n", len(idx.Dirs))
+
+	flush := time.NewTicker(flushEvery)
+	defer flush.Stop()
+
+	save := func() error {
+		if !live.dirty {
+			return nil
+		}
+		live.dirty = false
+		return Save(savePath, live.snapshot())
+	}
+
+	pending := make(map[string]bool)
+	var settle <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return save()
+			}
+			// Permission changes don't affect what is indexed
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[ev.Name] = true
+			settle = time.After(watchDebounce)
+			if len(pending) >= watchMaxPending {
+				live.apply(pending)
+				pending, settle = make(map[string]bool), nil
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return save()
+			}
+			log.Printf("Watch error: %v", err)
+			// Events were lost, so only a rescan of everything is reliable
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				live.rescan()
+			}
+
+		case <-settle:
+			live.apply(pending)
+			pending, settle = make(map[string]bool), nil
+
+		case <-flush.C:
+			if err := save(); err != nil {
+				log.Printf("Failed to save index: %v", err)
+			}
+
+		case <-ctx.Done():
+			live.apply(pending)
+			return save()
+		}
+	}
+}
+
+// liveIndex is an index kept in maps so single paths can be updated as
+// events arrive. dirs holds every directory being watched.
+type liveIndex struct {
+	roots   []string
+	opts    Options
+	entries map[string]FileEntry
+	dirs    map[string]time.Time
+	ignores map[string]time.Time // mtimes of the .gitignore files read, see Index.Ignores
+	watcher *fsnotify.Watcher
+	dirty   bool // changed since the last save
+}
+
+func newLiveIndex(idx *Index, opts Options, watcher *fsnotify.Watcher) *liveIndex {
+	l := &liveIndex{
+		roots:   idx.Roots,
+		opts:    opts,
+		entries: make(map[string]FileEntry, len(idx.Entries)),
+		dirs:    maps.Clone(idx.Dirs),
+		ignores: maps.Clone(idx.Ignores),
+		watcher: watcher,
+	}
+	if l.dirs == nil {
+		l.dirs = make(map[string]time.Time)
+	}
+	if l.ignores == nil {
+		l.ignores = make(map[string]time.Time)
+	}
+	for _, e := range idx.Entries {
+		l.entries[e.Path] = e
+	}
+	return l
+}
+
+// watch adds dir to the watcher. Failures, typically from hitting the
+// system's watch limit, are reported but leave the rest of the tree watched.
+func (l *liveIndex) watch(dir string) {
+	if err := l.watcher.Add(dir); err != nil {
+		log.Printf("Cannot watch %s: %v", dir, err)
+	}
+}
+
+// snapshot returns the current contents as an index, sorted by path and
+// with InterleaveRoots interleaved by root.
+func (l *liveIndex) snapshot() *Index {
+	entries := slices.Collect(maps.Values(l.entries))
+	slices.SortFunc(entries, func(a, b FileEntry) int { return strings.Compare(a.Path, b.Path) })
+	if l.opts.InterleaveRoots {
+		entries = interleaveRoots(entries, l.roots)
+	}
+	idx := newIndex(l.roots, l.opts)
+	idx.Entries, idx.Dirs, idx.Ignores = entries, maps.Clone(l.dirs), maps.Clone(l.ignores)
+	return idx
+}
+
+// apply brings the index in line with the current state of paths, which
+// are the names reported by events.
+func (l *liveIndex) apply(paths map[string]bool) {
+	// Sorted, so a new directory is scanned before events inside it, which
+	// then find it already known
+	for _, path := range slices.Sorted(maps.Keys(paths)) {
+		info, err := os.Lstat(path)
+		if err != nil {
+			l.remove(path)
+			continue
+		}
+		root := l.rootOf(path)
+		if root == "" {
+			continue
+		}
+		// Only paths in watched directories belong in the index; this also
+		// drops events for children of a directory removed in this burst
+		if _, ok := l.dirs[filepath.Dir(path)]; !ok && path != root {
+			continue
+		}
+
+		_, known := l.dirs[path]
+		if info.IsDir() && known {
+			// Changes inside it arrive as events of their own
+			continue
+		}
+		l.remove(path)
+		l.scan(root, path, info)
+	}
+}
+
+// rescan re-walks every root from scratch.
+func (l *liveIndex) rescan() {
+	for _, root := range l.roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			continue
+		}
+		l.remove(root)
+		l.scan(root, root, info)
+	}
+}
+
+// remove drops path and, if it was a directory, everything beneath it.
+func (l *liveIndex) remove(path string) {
+	if _, ok := l.entries[path]; ok {
+		delete(l.entries, path)
+		l.dirty = true
+	}
+	if _, ok := l.dirs[path]; !ok {
+		return
+	}
+	for dir := range l.dirs {
+		if IsWithin(path, dir) {
+			delete(l.dirs, dir)
+			delete(l.ignores, dir)
+			// The directory may already be gone, which also unwatches it
+			_ = l.watcher.Remove(dir)
+		}
+	}
+	for p := range l.entries {
+		if IsWithin(path, p) {
+			delete(l.entries, p)
+		}
+	}
+	l.dirty = true
+}
+
+// scan indexes path, described by info, with the same rules as a full
+// build, watching any directories it finds.
+func (l *liveIndex) scan(root, path string, info fs.FileInfo) {
+	var links *symlinkGuard
+	if l.opts.FollowSymlinks {
+		links = newSymlinkGuard(l.roots)
+	}
+	out := make(chan walkResult, 64)
+	w := newWalker(context.Background(), root, l.opts, nil, links, out)
+	w.prime(filepath.Dir(path))
+
+	go func() {
+		defer close(out)
+		if info.IsDir() {
+			_ = filepath.WalkDir(path, w.visit)
+		} else {
+			_ = w.visit(path, fs.FileInfoToDirEntry(info), nil)
+		}
+		w.leaveAll()
+	}()
+	for r := range out {
+		if r.err != nil {
+			log.Printf("Skipped: %v", r.err)
+			continue
+		}
+		if r.dir != "" {
+			l.dirs[r.dir] = r.dirMod
+			if r.ignoreMod.IsZero() {
+				delete(l.ignores, r.dir)
+			} else {
+				l.ignores[r.dir] = r.ignoreMod
+			}
+			l.watch(r.dir)
+		} else {
+			l.entries[r.entry.Path] = r.entry
+		}
+		l.dirty = true
+	}
+}
+
+// rootOf returns the root that path belongs to, or "" if none.
+func (l *liveIndex) rootOf(path string) string {
+	for _, root := range l.roots {
+		if IsWithin(root, path) {
+			return root
+		}
+	}
+	return ""
+}
+
+// prime loads the .gitignore rules of every directory from the walker's
+// root down to dir, so a walk can start in the middle of the tree.
+func (w *walker) prime(dir string) {
+	if w.ignore == nil || !IsWithin(w.root, dir) {
+		return
+	}
+	var chain []string
+	for d := dir; ; d = filepath.Dir(d) {
+		chain = append(chain, d)
+		if d == w.root || d == filepath.Dir(d) {
+			break
+		}
+	}
+	for _, d := range slices.Backward(chain) {
+		w.ignore.enter(d)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// ---------------------------------------------
+// LOGGING
+// ---------------------------------------------
+
+// logLevel is the threshold of logger, lowered by --verbose and raised by
+// --quiet.
+var logLevel = new(slog.LevelVar)
+
+// logger reports diagnostics such as the commands run to stderr as
+// key=value pairs. Fatal errors still go through the log package, so they
+// are printed however quiet the run.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	Level: logLevel,
+	ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		// Timestamps are noise in a CLI's output
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	},
+}))
+
+// addLogFlags registers --quiet and --verbose. The returned function
+// applies them once fset is parsed.
+func addLogFlags(fset *flag.FlagSet) func() {
+	quiet := fset.Bool("quiet", false, "print nothing but warnings and errors")
+	verbose := fset.Bool("verbose", false, "also print details such as skipped directories and the commands run")
+	return func() {
+		switch {
+		case *verbose:
+			logLevel.Set(slog.LevelDebug)
+		case *quiet:
+			logLevel.Set(slog.LevelWarn)
+		}
+	}
+}
+
+// verbose reports whether --verbose was given.
+func verbose() bool {
+	return logLevel.Level() <= slog.LevelDebug
+}
+
+// quiet reports whether --quiet was given.
+func quiet() bool {
+	return logLevel.Level() > slog.LevelInfo
+}
+
+// printf prints progress and summaries to stdout unless running quietly.
+func printf(format string, args ...any) {
+	if !quiet() {
+		fmt.Printf(format, args...)
+	}
+}
@@ -1,19 +1,27 @@
 package main
 
 import (
-	"encoding/gob"
+	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+
+	"filesearcher/indexer"
 )
 
 // ---------------------------------------------
@@ -21,293 +29,1775 @@ import (
 // ---------------------------------------------
 
 func main() {
-	indexPath, err := getIndexFilePath()
+	cfg, err := LoadConfig()
 	if err != nil {
-		log.Fatalf("System error: %v", err)
+		log.Fatalf("Config error: %v", err)
 	}
 
-	// CLI: Force re-index
-	if len(os.Args) > 1 && os.Args[1] == "index" {
-		if err := buildIndex(indexPath); err != nil {
-			log.Fatalf("Failed to build index: %v", err)
+	// CLI: Subcommands such as `index` to force a re-index
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(cfg, os.Args[2:])
+			return
 		}
-		return
 	}
 
-	// Auto-setup: Build if missing
-	if _, err := os.Stat(indexPath); errors.Is(err, os.ErrNotExist) {
-		fmt.Println("Index not found in home folder. Running setup...")
-		if err := buildIndex(indexPath); err != nil {
-			log.Fatalf("Failed to build index: %v", err)
+	opts, err := parseUIFlags(cfg, os.Args[1:])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	names := opts.Names
+	if opts.All {
+		if names, err = listIndexNames(); err != nil {
+			log.Fatalf("Cannot list indexes: %v", err)
 		}
 	}
 
-	files, err := loadIndex(indexPath)
-	if err != nil {
-		log.Fatalf("Failed to load index: %v", err)
+	var idx *indexer.Index
+	if len(names) <= 1 {
+		name := strings.Join(names, "")
+		indexPath := mustIndexPath(name)
+		idx = openIndex(cfg, indexPath)
+		if info, err := os.Stat(indexPath); err == nil {
+			opts.IndexedAt = info.ModTime()
+			reindexIfStale(name, idx, info.ModTime(), opts.MaxAge)
+		}
+	} else {
+		idxs := make([]*indexer.Index, len(names))
+		for i, name := range names {
+			indexPath := mustIndexPath(name)
+			if idxs[i], err = indexer.Load(indexPath); err != nil {
+				log.Fatalf("Failed to load index %s: %v", indexDisplayName(name), err)
+			}
+			info, err := os.Stat(indexPath)
+			if err != nil {
+				continue
+			}
+			// The stalest index decides how old the results may be
+			if opts.IndexedAt.IsZero() || info.ModTime().Before(opts.IndexedAt) {
+				opts.IndexedAt = info.ModTime()
+			}
+			reindexIfStale(name, idxs[i], info.ModTime(), opts.MaxAge)
+		}
+		idx, opts.Sources = mergeIndexes(names, idxs)
 	}
 
-	if len(files) == 0 {
+	if len(idx.Entries) == 0 {
 		fmt.Println("Index is empty. Try running `index` again.")
 		return
 	}
 
-	p := tea.NewProgram(initialModel(files), tea.WithAltScreen())
+	state := loadState()
+	opts.History = state.History
+	opts.Width, opts.Height = state.Width, state.Height
+	if !opts.Fresh {
+		opts.Query = state.LastQuery
+	}
+
+	progOpts := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if !indexer.IsTerminal(os.Stdout) {
+		// Captured as in dir="$(file-indexer)", so stdout is kept for the
+		// printed result and the UI is drawn on stderr instead
+		progOpts = append(progOpts, tea.WithOutput(os.Stderr))
+	}
+	p := tea.NewProgram(initialModel(idx, opts), progOpts...)
 	finalModel, err := p.Run()
 	if err != nil {
 		log.Fatalf("UI error: %v", err)
 	}
 
-	if m, ok := finalModel.(model); ok && m.selectedPath != "" {
-		openFileLocation(m.selectedPath)
+	m, ok := finalModel.(model)
+	if !ok {
+		return
+	}
+	state.History = m.history
+	state.LastQuery = m.query
+	if m.width > 0 && m.height > 0 {
+		state.Width, state.Height = m.width, m.height
+	}
+	if err := saveState(state); err != nil {
+		log.Printf("Could not save search history: %v", err)
+	}
+
+	// A cancelled session never acts, wherever the cursor was left
+	if m.cancelled || m.selectedPath == "" {
+		return
+	}
+	// The UI has released the alt screen by now, so a terminal editor can
+	// take over the terminal
+	switch {
+	case m.action == actionPrintDir:
+		fmt.Println(filepath.Dir(m.selectedPath))
+	case opts.PrintOnly:
+		fmt.Println(m.selectedPath)
+	case m.action == actionOpen && hasDisplay():
+		if err := openFile(m.selectedPath); err != nil {
+			log.Printf("Could not open the file: %v", err)
+		}
+	case m.action != actionOpen && cfg.openCommand() != "":
+		if err := runOpenCommand(cfg.openCommand(), m.selectedPath); err != nil {
+			log.Printf("Open command failed: %v", err)
+		}
+	case m.action != actionOpen && hasDisplay() && canReveal(opts.FileManager):
+		if err := openFileLocation(m.selectedPath, opts.FileManager); err != nil {
+			log.Printf("Could not reveal the file: %v", err)
+		}
+	default:
+		// Nothing graphical to show it in, as over SSH, so the path is
+		// at least there to copy
+		fmt.Println(m.selectedPath)
+	}
+}
+
+// openIndex loads the index at indexPath, building it first when it is
+// missing and rebuilding it when it is corrupt. It exits on failure.
+func openIndex(cfg Config, indexPath string) *indexer.Index {
+	// The roots file decides the roots, as for an explicit index command
+	build := func() error {
+		opts := cfg.indexOptions()
+		roots := rootsOrDefault(cfg, nil, &opts)
+		return buildIndex(context.Background(), indexPath, roots, opts)
+	}
+
+	// Auto-setup: Build if missing
+	if _, err := os.Stat(indexPath); errors.Is(err, os.ErrNotExist) {
+		printf("Index not found. Running setup...\n")
+		if err := build(); err != nil {
+			log.Fatalf("Failed to build index: %v", err)
+		}
 	}
+
+	idx, err := indexer.Load(indexPath)
+	if errors.Is(err, indexer.ErrCorrupt) {
+		// Self-heal, keeping the broken file for inspection
+		aside, mvErr := moveAside(indexPath)
+		if mvErr != nil {
+			log.Fatalf("Index is unreadable (%v) and could not be moved aside: %v", err, mvErr)
+		}
+		fmt.Printf("Index is unreadable (%v).\nMoved it to %s, rebuilding...\n", err, aside)
+		if err := build(); err != nil {
+			log.Fatalf("Failed to build index: %v", err)
+		}
+		idx, err = indexer.Load(indexPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load index: %v", err)
+	}
+	return idx
 }
 
 // ---------------------------------------------
 // UI MODEL
 // ---------------------------------------------
 
+// selectAction is what main does with the selected path after the UI exits.
+type selectAction int
+
+const (
+	actionReveal selectAction = iota // show the file in a file manager
+	actionOpen                       // open the file in its default application
+
+	// actionPrintDir prints the file's directory, for a shell function
+	// that changes to it, e.g. in ~/.bashrc:
+	//
+	//	fcd() { local dir; dir="$(file-indexer "$@")" && [ -d "$dir" ] && cd "$dir"; }
+	actionPrintDir
+)
+
 type model struct {
-	allFiles    []string
-	matches     []string
+	roots       []string
+	indexedAt   time.Time // mtime of the index file
+	hidden      bool      // the index includes hidden directories
+	allFiles    []indexer.FileEntry
+	sources     map[string]string // index name of every path when several are searched
+	theme       theme
+	footer      string                // footer template, see expandFooter
+	typeTags    typeTagStyle          // how rows show the type of their file
+	trigrams    *indexer.TrigramIndex // over allFiles; nil until built after startup
+	matches     []int                 // positions in allFiles
+	matchTotal  int                   // matches found, which exceeds len(matches) when capped
+	maxResults  int                   // result cap, 0 = unlimited
 	cursor      int
 	windowStart int
 	windowSize  int
+	maxWindow   int // preferred cap on windowSize, 0 = fill the terminal
+
+	query         string
+	caret         int // byte offset in query where typing inserts
+	mode          indexer.Mode
+	caseSensitive bool
+	smartCase     bool // match case anyway once the query has an upper-case letter
+	nameOnly      bool // match base names rather than full paths
+	sortOrder     indexer.SortOrder
+	rootFilter    string // only match files under this root; "" for all
+
+	// vim enables modal keys; in normalMode letters navigate instead of
+	// being typed into the query.
+	vim        bool
+	normalMode bool
+
+	showHelp bool // the help overlay replaces the search view
+
+	// grouped lists the matches under a header line per directory, drawn
+	// from rows, with windowStart counting rows rather than matches.
+	// rowOf gives each match's row, and collapsed directories show their
+	// header alone. See regroup.
+	grouped   bool
+	collapsed map[string]bool
+	rows      []groupRow
+	rowOf     []int
+
+	// jumping is set after Ctrl+G while jumpInput collects the number of
+	// the result to move the cursor to.
+	jumping   bool
+	jumpInput string
 
-	query        string
+	// preview shows the head of the file under the cursor below the
+	// results, read through previews so each file is read at most once.
+	preview  bool
+	previews *previewCache
+
+	// minTermLen holds off searching until some term is this long, and
+	// tooShort records that the current query fell short. listAll enables
+	// Alt+L, which lists the whole index while the query is empty.
+	minTermLen int
+	tooShort   bool
+	listAll    bool
+
+	// recent is set while Alt+R lists the recentCount most recently
+	// modified files in place of search results.
+	recent      bool
+	recentCount int
+
+	// regex caches the compiled query for regex mode, keyed by regexSrc,
+	// so it is compiled once per edit rather than once per file.
+	regex    *regexp.Regexp
+	regexSrc string
+	regexErr error
+
+	// searchGen identifies the newest search; results from older ones are
+	// dropped. cancelSearch stops the one in flight. While searching, a
+	// spinner tick is pending whenever spinning is set.
+	searchGen    int
+	searching    bool
+	cancelSearch context.CancelFunc
+	spinning     bool
+	spinFrame    int
+
+	// history holds past queries, oldest first. historyPos is the entry
+	// being shown while recalling, or len(history) when not recalling, in
+	// which case draft keeps the query that was being typed.
+	history    []string
+	historyPos int
+	draft      string
+
+	// lastClick and lastClickRow detect double clicks.
+	lastClick    time.Time
+	lastClickRow int
+
+	initCmd      tea.Cmd // returned by Init
 	selectedPath string
+	cancelled    bool   // quit with Esc, Ctrl+C or q, so nothing is to be done
+	notice       string // one-off message shown until the next key press
+	action       selectAction
 	width        int
 	height       int
 }
 
-func initialModel(files []string) model {
-	return model{
-		allFiles:   files,
-		matches:    nil,
-		cursor:     0,
-		windowSize: 15,
+// uiOptions are the settings the interactive UI starts with.
+type uiOptions struct {
+	Mode          indexer.Mode
+	Sort          indexer.SortOrder
+	CaseSensitive bool
+	SmartCase     bool
+	Names         []string          // named indexes to search together, none for the default
+	All           bool              // search every index there is
+	Sources       map[string]string // index name of every path, see mergeIndexes
+	Vim           bool
+	Preview       bool
+	FileManager   string // reveal command template, "" to auto-detect
+	WindowSize    int
+	MaxResults    int
+	History       []string
+	IndexedAt     time.Time
+	MaxAge        time.Duration // rebuild older indexes in the background, 0 = never
+	MinTermLength int
+	ListAll       bool
+	RecentFiles   int
+	Query         string // initial query, searched for on start
+	Fresh         bool   // don't restore the previous session's query
+	PrintOnly     bool   // print the selection rather than open or reveal it
+	Footer        string // footer template, "" for defaultFooter
+	TypeTags      typeTagStyle
+	Theme         theme
+	Width, Height int // last known terminal size, 0 if unknown
+}
+
+func initialModel(idx *indexer.Index, opts uiOptions) model {
+	windowSize := 15
+	if opts.WindowSize > 0 {
+		windowSize = opts.WindowSize
+	}
+	m := model{
+		roots:         idx.Roots,
+		indexedAt:     opts.IndexedAt,
+		hidden:        idx.IncludeHidden,
+		sources:       opts.Sources,
+		allFiles:      idx.Entries,
+		matches:       nil,
+		cursor:        0,
+		windowSize:    windowSize,
+		maxWindow:     opts.WindowSize,
+		footer:        opts.Footer,
+		typeTags:      opts.TypeTags,
+		maxResults:    opts.MaxResults,
+		mode:          opts.Mode,
+		sortOrder:     opts.Sort,
+		caseSensitive: opts.CaseSensitive,
+		smartCase:     opts.SmartCase,
+		vim:           opts.Vim,
+		preview:       opts.Preview,
+		previews:      newPreviewCache(),
+		history:       opts.History,
+		historyPos:    len(opts.History),
+		query:         opts.Query,
+		caret:         len(opts.Query),
+		minTermLen:    opts.MinTermLength,
+		listAll:       opts.ListAll,
+		recentCount:   opts.RecentFiles,
+	}
+	if m.footer == "" {
+		m.footer = defaultFooter
 	}
+	if m.theme = opts.Theme; m.theme == (theme{}) {
+		m.theme = builtinThemes[defaultThemeName]
+	}
+	// Laid out for the last known size until the terminal reports its own
+	m.width, m.height = opts.Width, opts.Height
+	m.layout()
+	// Started here rather than in Init, whose changes to the model are lost
+	if m.query != "" {
+		m.initCmd = m.performSearch()
+	}
+	return m
 }
 
-func (m model) Init() tea.Cmd { return nil }
+// trigramsReadyMsg delivers the trigram index built in the background.
+type trigramsReadyMsg struct {
+	trigrams *indexer.TrigramIndex
+}
+
+func (m model) Init() tea.Cmd {
+	// Searches scan linearly until the trigram index is ready
+	entries := m.allFiles
+	buildTrigrams := func() tea.Msg {
+		return trigramsReadyMsg{trigrams: indexer.NewTrigramIndex(entries)}
+	}
+	return tea.Batch(m.initCmd, buildTrigrams)
+}
+
+// searchResultMsg delivers the matches of the search started as gen.
+type searchResultMsg struct {
+	gen     int
+	matches []int
+	total   int // matches before the result cap
+}
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
 	switch msgTyped := msg.(type) {
 
+	case searchResultMsg:
+		if msgTyped.gen != m.searchGen {
+			// A newer query has been typed since this search started
+			break
+		}
+		m.searching = false
+		m.replaceMatches(msgTyped.matches)
+		m.matchTotal = msgTyped.total
+
+	case trigramsReadyMsg:
+		m.trigrams = msgTyped.trigrams
+
+	case clipboardMsg:
+		if msgTyped.text != "" {
+			cmd = m.insertQuery(msgTyped.text)
+		}
+
+	case copiedMsg:
+		switch n := msgTyped.count; {
+		case msgTyped.err != nil:
+			m.notice = fmt.Sprintf("Could not copy the matches: %v", msgTyped.err)
+		case n < msgTyped.total:
+			m.notice = fmt.Sprintf("Copied the first %d of %d matches (the result cap) to the clipboard.", n, msgTyped.total)
+		case n == 1:
+			m.notice = "Copied 1 path to the clipboard."
+		default:
+			m.notice = fmt.Sprintf("Copied %d paths to the clipboard.", n)
+		}
+
+	case spinnerTickMsg:
+		m.spinning = m.searching
+		if m.spinning {
+			m.spinFrame++
+			cmd = spinnerTick()
+		}
+
 	case tea.WindowSizeMsg:
 		m.width, m.height = msgTyped.Width, msgTyped.Height
-		if m.height > 5 {
-			m.windowSize = m.height - 5
+		m.layout()
+
+	case tea.MouseMsg:
+		if !m.showHelp {
+			cmd = m.handleMouse(msgTyped)
 		}
 
 	case tea.KeyMsg:
+		m.notice = ""
+		if cmd, handled := m.handleHelpKey(msgTyped); handled {
+			return m, cmd
+		}
+		if m.jumping {
+			if m.handleJumpKey(msgTyped) {
+				return m, nil
+			}
+		}
+		// The first Esc only stops a slow search, the next one quits
+		if msgTyped.Type == tea.KeyEsc && m.searching {
+			m.stopSearch()
+			m.notice = "Search cancelled, showing the previous results."
+			return m, nil
+		}
+		if m.vim {
+			if cmd, handled := m.handleVimKey(msgTyped); handled {
+				return m, cmd
+			}
+		}
+
 		switch msgTyped.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
-			return m, tea.Quit
+			return m, m.quit()
+
+		case tea.KeyCtrlP:
+			cmd = m.recallHistory(-1)
+
+		case tea.KeyCtrlN:
+			cmd = m.recallHistory(1)
 
+		case tea.KeyCtrlF:
+			cmd = m.toggleMode(indexer.ModeFuzzy)
+
+		case tea.KeyCtrlR:
+			cmd = m.toggleMode(indexer.ModeRegex)
+
+		case tea.KeyCtrlG:
+			m.jumping, m.jumpInput = true, ""
+
+		// Browsing the results counts as settling on the query
 		case tea.KeyUp:
-			if m.cursor > 0 {
-				m.cursor--
-				if m.cursor < m.windowStart {
-					m.windowStart--
-				}
-			}
+			m.commitQuery()
+			m.moveCursor(-1)
 
 		case tea.KeyDown:
-			if m.cursor < len(m.matches)-1 {
-				m.cursor++
-				if m.cursor >= m.windowStart+m.windowSize {
-					m.windowStart++
-				}
-			}
+			m.commitQuery()
+			m.moveCursor(1)
+
+		case tea.KeyPgUp:
+			m.commitQuery()
+			m.scroll(-m.windowSize)
+
+		case tea.KeyPgDown:
+			m.commitQuery()
+			m.scroll(m.windowSize)
+
+		case tea.KeyHome:
+			m.commitQuery()
+			m.setCursor(0)
+
+		case tea.KeyEnd:
+			m.commitQuery()
+			m.setCursor(m.lastMatch())
 
 		case tea.KeyEnter:
-			if len(m.matches) > 0 {
-				m.selectedPath = m.matches[m.cursor]
+			if m.selectCurrent(actionReveal) {
+				return m, tea.Quit
+			}
+
+		case tea.KeyCtrlO:
+			if m.selectCurrent(actionOpen) {
+				return m, tea.Quit
+			}
+
+		case tea.KeyCtrlL:
+			if m.selectCurrent(actionPrintDir) {
 				return m, tea.Quit
 			}
 
-		case tea.KeyBackspace, tea.KeyDelete:
-			if len(m.query) > 0 {
-				m.query = m.query[:len(m.query)-1]
-				m.performSearch()
+		case tea.KeyLeft:
+			m.moveCaret(-1)
+
+		case tea.KeyRight:
+			m.moveCaret(1)
+
+		case tea.KeyCtrlA:
+			m.caret = 0
+
+		case tea.KeyCtrlE:
+			m.caret = len(m.query)
+
+		case tea.KeyBackspace:
+			if m.caret > 0 {
+				_, size := utf8.DecodeLastRuneInString(m.query[:m.caret])
+				cmd = m.cutQuery(m.caret-size, m.caret)
+			}
+
+		case tea.KeyDelete:
+			if m.caret < len(m.query) {
+				_, size := utf8.DecodeRuneInString(m.query[m.caret:])
+				cmd = m.cutQuery(m.caret, m.caret+size)
+			}
+
+		case tea.KeyCtrlV:
+			cmd = pasteClipboard
+
+		case tea.KeyTab:
+			cmd = m.completeQuery()
+
+		case tea.KeyCtrlW:
+			before := strings.TrimRight(m.query[:m.caret], " ")
+			start := strings.LastIndexByte(before, ' ') + 1
+			if start < m.caret {
+				cmd = m.cutQuery(start, m.caret)
 			}
 
 		case tea.KeyRunes:
-			m.query += string(msgTyped.Runes)
-			m.performSearch()
+			if msgTyped.Alt {
+				cmd = m.handleAltKey(msgTyped)
+				break
+			}
+			// Pasted text arrives here too and may carry newlines
+			if text := sanitizeInput(msgTyped.Runes); text != "" {
+				cmd = m.insertQuery(text)
+			}
 
 		case tea.KeySpace:
-			m.query += " "
-			m.performSearch()
+			cmd = m.insertQuery(" ")
 		}
 	}
-	return m, nil
+	return m, cmd
 }
 
-func (m *model) performSearch() {
-	m.matches = m.matches[:0]
-	m.cursor = 0
-	m.windowStart = 0
+// selectCurrent records the match under the cursor and the action to take
+// on it, reporting false when there is nothing to select. A file that has
+// vanished since indexing is reported in the UI instead of being selected.
+func (m *model) selectCurrent(action selectAction) bool {
+	if m.cursor < 0 || m.cursor >= len(m.matches) {
+		return false
+	}
+	if m.onCollapsedHeader() {
+		m.toggleCollapsed()
+		return false
+	}
+	path := m.matchPath(m.cursor)
+	if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+		m.notice = fmt.Sprintf("No longer exists: %s (run `prune` or `index` to refresh)", path)
+		return false
+	}
+	m.selectedPath = path
+	m.action = action
+	m.commitQuery()
+	return true
+}
 
-	q := strings.ToLower(strings.TrimSpace(m.query))
-	if q == "" {
-		return
+// handleMouse scrolls the results with the wheel and moves the cursor to
+// a clicked row; clicking the row under the cursor again soon after opens
+// it. Clicks outside the result rows are ignored.
+func (m *model) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	const wheelRows = 3
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.scrollWindow(-wheelRows)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.scrollWindow(wheelRows)
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		row := m.windowStart + msg.Y - listTop
+		if msg.Y < listTop || row >= min(m.windowStart+m.windowSize, m.rowCount()) {
+			return nil
+		}
+		m.commitQuery()
+		i, ok := m.rowMatch(row)
+		if !ok {
+			// The header of an expanded directory collapses it
+			m.setCursor(m.rows[row].match)
+			m.toggleCollapsed()
+			return nil
+		}
+		double := row == m.lastClickRow && time.Since(m.lastClick) < doubleClickTime
+		m.lastClick, m.lastClickRow = time.Now(), row
+		m.setCursor(i)
+		if double && m.selectCurrent(actionOpen) {
+			return tea.Quit
+		}
 	}
+	return nil
+}
 
-	terms := strings.Fields(q)
-	matchCount := 0
+// doubleClickTime is the longest gap between the clicks of a double click.
+const doubleClickTime = 400 * time.Millisecond
 
-	for _, file := range m.allFiles {
-		lower := strings.ToLower(file)
-		matched := true
-		for _, term := range terms {
-			if !strings.Contains(lower, term) {
-				matched = false
-				break
-			}
+// scrollWindow moves the window by delta rows without moving the cursor
+// further than needed to keep it visible.
+func (m *model) scrollWindow(delta int) {
+	m.windowStart = max(min(m.windowStart+delta, m.rowCount()-m.windowSize), 0)
+	row := m.matchRow(m.cursor)
+	switch end := m.windowStart + m.windowSize; {
+	case row < m.windowStart:
+		row = m.windowStart
+		for _, ok := m.rowMatch(row); !ok && row < end-1; _, ok = m.rowMatch(row) {
+			row++
+		}
+	case row >= end:
+		row = end - 1
+		for _, ok := m.rowMatch(row); !ok && row > m.windowStart; _, ok = m.rowMatch(row) {
+			row--
+		}
+	}
+	if i, ok := m.rowMatch(row); ok {
+		m.cursor = i
+	}
+	m.clampCursor()
+}
+
+// sanitizeInput drops control characters, newlines included, from typed
+// or pasted runes so the query always stays a single clean line.
+func sanitizeInput(runes []rune) string {
+	var sb strings.Builder
+	for _, r := range runes {
+		if !unicode.IsControl(r) && r != utf8.RuneError {
+			sb.WriteRune(r)
 		}
+	}
+	return sb.String()
+}
 
-		if matched {
-			m.matches = append(m.matches, file)
-			matchCount++
-			if matchCount >= 1000 {
-				break
+// insertQuery types text at the caret, leaving the caret after it.
+func (m *model) insertQuery(text string) tea.Cmd {
+	m.query = m.query[:m.caret] + text + m.query[m.caret:]
+	m.caret += len(text)
+	return m.queryEdited()
+}
+
+// cutQuery deletes the bytes of the query from start to end, which must
+// lie on rune boundaries, leaving the caret where they were.
+func (m *model) cutQuery(start, end int) tea.Cmd {
+	m.query = m.query[:start] + m.query[end:]
+	m.caret = start
+	return m.queryEdited()
+}
+
+// moveCaret moves the caret delta runes, stopping at either end of the
+// query.
+func (m *model) moveCaret(delta int) {
+	for ; delta < 0 && m.caret > 0; delta++ {
+		_, size := utf8.DecodeLastRuneInString(m.query[:m.caret])
+		m.caret -= size
+	}
+	for ; delta > 0 && m.caret < len(m.query); delta-- {
+		_, size := utf8.DecodeRuneInString(m.query[m.caret:])
+		m.caret += size
+	}
+}
+
+// completeQuery extends the last term of the query, fzf-style, with the
+// text following it in every match, up to where they differ or reach a
+// space, and searches again. It does nothing unless the query ends in a
+// plain term and substring mode lists every match.
+func (m *model) completeQuery() tea.Cmd {
+	if m.mode != indexer.ModeSubstring || m.recent || m.searching || len(m.matches) == 0 || m.matchTotal > len(m.matches) {
+		return nil
+	}
+	terms := indexer.QueryTerms(m.query, m.searchOptions())
+	last := m.query[strings.LastIndexAny(m.query, " \t")+1:]
+	fold := strings.ToLower
+	if m.matchCase() {
+		fold = func(s string) string { return s }
+	}
+	// Operators and quoted phrases are left alone
+	if len(terms) == 0 || last == "" || fold(last) != terms[len(terms)-1] {
+		return nil
+	}
+	term := terms[len(terms)-1]
+
+	var common string
+	for i := range m.matches {
+		text := m.matchPath(i)
+		if m.nameOnly {
+			text = filepath.Base(text)
+		}
+		text = fold(text)
+		at := strings.Index(text, term)
+		if at < 0 {
+			return nil
+		}
+		rest := text[at+len(term):]
+		if i == 0 {
+			common = rest
+		}
+		n := 0
+		for n < len(common) && n < len(rest) && common[n] == rest[n] {
+			n++
+		}
+		common = common[:n]
+		if common == "" {
+			return nil
+		}
+	}
+	if i := strings.IndexAny(common, " \t"); i >= 0 {
+		common = common[:i]
+	}
+	// The bytes compared may end inside a character
+	for common != "" && !utf8.ValidString(common) {
+		common = common[:len(common)-1]
+	}
+	if common == "" {
+		return nil
+	}
+	m.caret = len(m.query)
+	return m.insertQuery(common)
+}
+
+// setQuery replaces the whole query, leaving the caret at its end.
+func (m *model) setQuery(q string) {
+	m.query, m.caret = q, len(q)
+}
+
+// quit stops any running search and ends the program, cancelling the
+// session: unlike selectCurrent it leaves main nothing to act on.
+func (m *model) quit() tea.Cmd {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	m.commitQuery()
+	m.cancelled, m.selectedPath = true, ""
+	return tea.Quit
+}
+
+// handleVimKey implements the modal keymap: Esc leaves insert mode, and in
+// normal mode j/k, g/G and Ctrl+D/Ctrl+U navigate while i or / return to
+// typing. Keys it doesn't handle fall through to the regular bindings.
+func (m *model) handleVimKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if !m.normalMode {
+		if msg.Type == tea.KeyEsc {
+			m.normalMode = true
+			return nil, true
+		}
+		return nil, false
+	}
+
+	half := max(m.windowSize/2, 1)
+	switch msg.Type {
+	case tea.KeyEsc:
+		return m.quit(), true
+	case tea.KeyCtrlD:
+		m.commitQuery()
+		m.scroll(half)
+		return nil, true
+	case tea.KeyCtrlU:
+		m.commitQuery()
+		m.scroll(-half)
+		return nil, true
+	case tea.KeySpace, tea.KeyBackspace, tea.KeyDelete, tea.KeyCtrlW, tea.KeyCtrlV:
+		return nil, true
+	case tea.KeyRunes:
+		if msg.Alt {
+			return nil, false
+		}
+		switch msg.String() {
+		case "j":
+			m.commitQuery()
+			m.moveCursor(1)
+		case "k":
+			m.commitQuery()
+			m.moveCursor(-1)
+		case "g":
+			m.commitQuery()
+			m.setCursor(0)
+		case "G":
+			m.commitQuery()
+			m.setCursor(m.lastMatch())
+		case "i", "/":
+			m.normalMode = false
+		case "q":
+			return m.quit(), true
+		}
+		// Other letters are swallowed rather than typed
+		return nil, true
+	}
+	return nil, false
+}
+
+// handleJumpKey reads the result number typed after Ctrl+G, counting from
+// 1 as the footer does. Enter moves the cursor there, clamped to the last
+// match, and Esc or Ctrl+G gives up; any other key ends the jump and is
+// left to the regular bindings.
+func (m *model) handleJumpKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyRunes:
+		if !msg.Alt && strings.Trim(string(msg.Runes), "0123456789") == "" {
+			// Anything longer is past the last match anyway
+			if len(m.jumpInput) < 9 {
+				m.jumpInput += string(msg.Runes)
 			}
+			return true
+		}
+	case tea.KeyBackspace:
+		if m.jumpInput != "" {
+			m.jumpInput = m.jumpInput[:len(m.jumpInput)-1]
+		}
+		return true
+	case tea.KeyEnter:
+		m.jumping = false
+		if n, err := strconv.Atoi(m.jumpInput); err == nil {
+			m.commitQuery()
+			m.setCursor(n - 1)
+		}
+		return true
+	case tea.KeyEsc, tea.KeyCtrlG:
+		m.jumping = false
+		return true
+	}
+	m.jumping = false
+	return false
+}
+
+// handleAltKey dispatches the Alt+letter mode toggles.
+func (m *model) handleAltKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "alt+c":
+		m.caseSensitive = !m.caseSensitive
+		return m.performSearch()
+	case "alt+a":
+		return m.toggleMode(indexer.ModeAcronym)
+	case "alt+n":
+		m.nameOnly = !m.nameOnly
+		return m.performSearch()
+	case "alt+t":
+		switch m.sortOrder {
+		case indexer.SortRelevance:
+			m.sortOrder = indexer.SortModTime
+		case indexer.SortModTime:
+			m.sortOrder = indexer.SortPath
+		default:
+			m.sortOrder = indexer.SortRelevance
+		}
+		return m.performSearch()
+	case "alt+l":
+		if m.listAll && m.query == "" {
+			m.stopSearch()
+			m.recent = false
+			m.matches, m.matchTotal = allPositions(len(m.allFiles)), len(m.allFiles)
+			m.regroup()
+			m.cursor, m.windowStart = 0, 0
+		}
+	case "alt+r":
+		if m.query != "" {
+			break
+		}
+		m.stopSearch()
+		m.recent = !m.recent
+		m.matches, m.matchTotal = nil, 0
+		if m.recent {
+			m.matches = indexer.Recent(m.allFiles, m.recentCount)
+			m.matchTotal = len(m.matches)
+		}
+		m.regroup()
+		m.cursor, m.windowStart = 0, 0
+	case "alt+s":
+		// Cycles through every root, then back to all of them
+		if len(m.roots) < 2 {
+			break
+		}
+		i := slices.Index(m.roots, m.rootFilter)
+		m.rootFilter = ""
+		if i+1 < len(m.roots) {
+			m.rootFilter = m.roots[i+1]
+		}
+		return m.performSearch()
+	case "alt+p":
+		m.preview = !m.preview
+		m.layout()
+	case "alt+y":
+		return m.copyMatches()
+	case "alt+g":
+		m.toggleGroups()
+	case "alt+z":
+		m.toggleCollapsed()
+	}
+	return nil
+}
+
+// layout sizes the result window to the terminal, leaving room for the
+// preview pane when it is shown, then scrolls so the cursor stays on
+// screen: shrinking the terminal must not leave it below the window.
+func (m *model) layout() {
+	if m.height == 0 {
+		// Size not reported yet
+		return
+	}
+	rows := m.height - 5
+	if m.preview {
+		rows -= previewLines + 2
+	}
+	// A terminal too small for the chrome still shows the cursor row
+	m.windowSize = max(rows, 1)
+	if m.maxWindow > 0 {
+		m.windowSize = min(m.windowSize, m.maxWindow)
+	}
+	m.clampCursor()
+}
+
+// toggleMode switches between mode and the default substring mode.
+func (m *model) toggleMode(mode indexer.Mode) tea.Cmd {
+	if m.mode == mode {
+		m.mode = indexer.ModeSubstring
+	} else {
+		m.mode = mode
+	}
+	return m.performSearch()
+}
+
+// searchOptions returns the matching settings currently selected in the UI.
+func (m model) searchOptions() indexer.SearchOptions {
+	opts := indexer.SearchOptions{Mode: m.mode, CaseSensitive: m.matchCase(), Limit: m.maxResults, NameOnly: m.nameOnly, Sort: m.sortOrder, Roots: m.roots, Root: m.rootFilter, Trigrams: m.trigrams}
+	if m.mode == indexer.ModeRegex {
+		opts.Regexp = m.regex
+	}
+	return opts
+}
+
+// matchCase reports whether the current query is matched case-sensitively:
+// when asked to, or with smart case when a term has an upper-case letter.
+func (m model) matchCase() bool {
+	return m.caseSensitive || m.smartCase && indexer.SmartCase(m.query, m.mode)
+}
+
+// compileRegex refreshes the cached regex for the current query. On error
+// the previous regex and its results are kept so the list doesn't blank
+// out while a pattern is half typed.
+func (m *model) compileRegex() error {
+	src := strings.TrimSpace(m.query)
+	if !m.matchCase() {
+		src = "(?i)" + src
+	}
+	if src == m.regexSrc {
+		return m.regexErr
+	}
+	m.regexSrc = src
+	re, err := indexer.CompileRegexp(m.query, m.matchCase())
+	m.regexErr = err
+	if err == nil {
+		m.regex = re
+	}
+	return err
+}
+
+// queryEdited re-runs the search after the user changed the query by hand,
+// which also ends any history recall in progress.
+func (m *model) queryEdited() tea.Cmd {
+	m.historyPos = len(m.history)
+	return m.performSearch()
+}
+
+// commitQuery adds the current query to the history unless it is empty or
+// repeats the most recent entry.
+func (m *model) commitQuery() {
+	q := strings.TrimSpace(m.query)
+	if q != "" && (len(m.history) == 0 || m.history[len(m.history)-1] != q) {
+		m.history = append(m.history, q)
+	}
+	m.historyPos = len(m.history)
+}
+
+// recallHistory steps through past queries, delta -1 for older and +1 for
+// newer. Stepping past the newest entry restores the in-progress draft.
+func (m *model) recallHistory(delta int) tea.Cmd {
+	pos := m.historyPos + delta
+	if pos < 0 || pos > len(m.history) {
+		return nil
+	}
+	if m.historyPos == len(m.history) {
+		m.draft = m.query
+	}
+	m.historyPos = pos
+	if pos == len(m.history) {
+		m.setQuery(m.draft)
+	} else {
+		m.setQuery(m.history[pos])
+	}
+	return m.performSearch()
+}
+
+// matchPath returns the path of the i'th match.
+func (m model) matchPath(i int) string {
+	return m.allFiles[m.matches[i]].Path
+}
+
+// allPositions returns the positions of every one of n entries, in order.
+func allPositions(n int) []int {
+	positions := make([]int, n)
+	for i := range positions {
+		positions[i] = i
+	}
+	return positions
+}
+
+// setCursor moves the cursor to i, clamped to the match list, and scrolls
+// the window just enough to keep it visible. In a collapsed directory the
+// cursor moves to its header.
+func (m *model) setCursor(i int) {
+	m.cursor = max(min(i, len(m.matches)-1), 0)
+	row := m.matchRow(m.cursor)
+	if i, ok := m.rowMatch(row); ok {
+		m.cursor = i
+	}
+	top := row
+	if m.grouped && row > 0 && m.rows[row-1].header {
+		// With its directory's header in view
+		top = row - 1
+	}
+	if top < m.windowStart {
+		m.windowStart = top
+	}
+	if row >= m.windowStart+m.windowSize {
+		m.windowStart = row - m.windowSize + 1
+	}
+	m.windowStart = max(min(m.windowStart, m.rowCount()-m.windowSize), 0)
+}
+
+// moveCursor moves the cursor delta rows down, or up when negative,
+// passing over the headers of expanded directories.
+func (m *model) moveCursor(delta int) {
+	step := 1
+	if delta < 0 {
+		step, delta = -1, -delta
+	}
+	row := m.matchRow(m.cursor)
+	for ; delta > 0; delta-- {
+		next := row + step
+		for _, ok := m.rowMatch(next); !ok && next >= 0 && next < m.rowCount(); _, ok = m.rowMatch(next) {
+			next += step
+		}
+		if next < 0 || next >= m.rowCount() {
+			break
 		}
+		row = next
+	}
+	if i, ok := m.rowMatch(row); ok {
+		m.setCursor(i)
+	}
+}
+
+// lastMatch returns the match on the last row of the list.
+func (m model) lastMatch() int {
+	i, _ := m.rowMatch(m.rowCount() - 1)
+	return i
+}
+
+// replaceMatches swaps in the matches of a new search. If the selected path
+// is among them the cursor follows it, staying on the same screen row where
+// possible; otherwise the cursor returns to the top.
+func (m *model) replaceMatches(matches []int) {
+	selected, row := -1, m.matchRow(m.cursor)-m.windowStart
+	if m.cursor < len(m.matches) {
+		selected = m.matches[m.cursor]
+	}
+	m.matches = matches
+	m.regroup()
+	i := slices.Index(matches, selected)
+	if i < 0 {
+		m.cursor, m.windowStart = 0, 0
+		m.clampCursor()
+		return
+	}
+	m.windowStart = max(m.matchRow(i)-row, 0)
+	m.setCursor(i)
+}
+
+// scroll moves both the window and the cursor by delta rows, stopping at
+// either end of the match list.
+func (m *model) scroll(delta int) {
+	m.windowStart = max(min(m.windowStart+delta, m.rowCount()-m.windowSize), 0)
+	m.moveCursor(delta)
+}
+
+// performSearch starts matching the current query in the background,
+// cancelling any search still running. The current matches stay on screen
+// until a searchResultMsg for this generation replaces them.
+func (m *model) performSearch() tea.Cmd {
+	m.recent = false
+	if m.mode == indexer.ModeRegex && m.compileRegex() != nil {
+		return nil
+	}
+	if m.tooShort = m.queryTooShort(); m.tooShort {
+		m.stopSearch()
+		m.matches, m.matchTotal = nil, 0
+		m.regroup()
+		return nil
+	}
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
+	m.searchGen++
+	m.searching = true
+
+	gen, entries, query := m.searchGen, m.allFiles, m.query
+	opts := m.searchOptions()
+	run := func() tea.Msg {
+		defer cancel()
+		matches, total := indexer.SearchContext(ctx, entries, query, opts)
+		return searchResultMsg{gen: gen, matches: matches, total: total}
+	}
+	if m.spinning {
+		return run
 	}
+	m.spinning = true
+	return tea.Batch(run, spinnerTick())
 }
 
+// spinnerInterval is how often the searching spinner advances.
+const spinnerInterval = 100 * time.Millisecond
+
+// spinnerTickMsg advances the searching spinner.
+type spinnerTickMsg struct{}
+
+func spinnerTick() tea.Cmd {
+	return tea.Tick(spinnerInterval, func(time.Time) tea.Msg { return spinnerTickMsg{} })
+}
+
+// stopSearch cancels the search in flight and makes sure its results, if
+// already on their way, are dropped.
+func (m *model) stopSearch() {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	m.searchGen++
+	m.searching = false
+}
+
+// queryTooShort reports whether minTermLen is set and no term of the query
+// reaches it. Queries made only of operators such as ext:go are exempt.
+func (m model) queryTooShort() bool {
+	query := strings.TrimSpace(m.query)
+	if m.minTermLen <= 0 || query == "" {
+		return false
+	}
+	if m.mode == indexer.ModeRegex {
+		return utf8.RuneCountInString(query) < m.minTermLen
+	}
+	terms := indexer.QueryTerms(query, m.searchOptions())
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) >= m.minTermLen {
+			return false
+		}
+	}
+	return len(terms) > 0
+}
+
+// clampCursor re-validates cursor and windowStart against the current
+// matches, keeping the cursor in range and on screen.
+func (m *model) clampCursor() {
+	m.setCursor(m.cursor)
+}
+
+// listTop is the screen row View draws the first result on.
+const listTop = 4
+
 func (m model) View() string {
+	if m.showHelp {
+		return m.helpView()
+	}
 	var sb strings.Builder
 
-	sb.WriteString("\n  Search (Esc to quit)\n")
-	sb.WriteString(fmt.Sprintf("  > %s\u2588\n\n", m.query))
+	header := "Search"
+	if len(m.roots) > 0 {
+		header += " " + strings.Join(m.roots, ", ")
+	}
+	if m.recent {
+		header += " [recent]"
+	} else if m.mode != indexer.ModeSubstring {
+		header += fmt.Sprintf(" [%s]", m.mode)
+	}
+	if m.matchCase() {
+		header += " [case-sensitive]"
+	}
+	if m.nameOnly {
+		header += " [name only]"
+	}
+	if m.rootFilter != "" {
+		header += " [in " + m.rootFilter + "]"
+	}
+	quitHint := "Enter to select, Esc to cancel"
+	if m.vim {
+		if m.normalMode {
+			header = "-- NORMAL -- " + header
+			quitHint = "i to type, q to cancel"
+		} else {
+			header = "-- INSERT -- " + header
+			quitHint = "Esc for normal mode"
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n  %s (%s, ? for help)\n", header, quitHint))
 
-	if len(m.matches) == 0 && m.query != "" {
-		sb.WriteString("  No matches found.\n")
-		return sb.String()
+	status := ""
+	switch {
+	case m.mode == indexer.ModeRegex && m.regexErr != nil:
+		status = "  " + paint(m.theme.Error, m.regexErr.Error())
+	case m.searching:
+		frame := indexer.SpinnerFrames[m.spinFrame%len(indexer.SpinnerFrames)]
+		status = "  " + paint(m.theme.Dim, string(frame)+" searching\u2026 (Esc to cancel)")
+	}
+	if m.jumping {
+		sb.WriteString(fmt.Sprintf("  Go to result: %s\u2588  %s\n\n", m.jumpInput, paint(m.theme.Dim, "(Enter to jump, Esc to cancel)")))
+	} else {
+		sb.WriteString(fmt.Sprintf("  > %s\u2588%s%s\n\n", m.query[:m.caret], m.query[m.caret:], status))
 	}
 
-	end := m.windowStart + m.windowSize
-	if end > len(m.matches) {
-		end = len(m.matches)
+	switch {
+	case m.tooShort:
+		sb.WriteString(fmt.Sprintf("  Type at least %d characters to search.\n", m.minTermLen))
+	case len(m.matches) == 0 && m.query != "" && !m.searching:
+		sb.WriteString("  No matches found.\n")
 	}
 
-	for i := m.windowStart; i < end; i++ {
-		cursor := " "
-		line := m.matches[i]
+	start := max(min(m.windowStart, m.rowCount()), 0)
+	end := min(start+m.windowSize, m.rowCount())
 
-		if i == m.cursor {
+	opts := m.searchOptions()
+	terms := indexer.QueryTerms(m.query, opts)
+	for r := start; r < end; r++ {
+		i, _ := m.rowMatch(r)
+		cursor := " "
+		style := ""
+		if i == m.cursor && m.matchRow(i) == r {
 			cursor = ">"
-			line = fmt.Sprintf("\033[1;36m%s\033[0m", line)
+			style = sgr(m.theme.Cursor)
+		}
+		if m.grouped && m.rows[r].header {
+			sb.WriteString(fmt.Sprintf("%s %s\n", cursor, m.groupHeader(m.rows[r], style)))
+			continue
+		}
+
+		path := m.matchPath(i)
+		tag := ""
+		if source, ok := m.sources[path]; ok {
+			tag = " [" + source + "]"
+		}
+		text, ranges := path, indexer.MatchRanges(path, terms, opts)
+		indent := ""
+		if m.grouped {
+			// The header above already shows the directory
+			text, ranges = baseName(text, ranges)
+			indent = "  "
+		}
+		if m.width > 0 {
+			// One line per row, or wrapping would break the window math
+			text, ranges = fitPath(text, ranges, m.width-2-len(indent)-m.typeTagWidth()-runewidth.StringWidth(tag))
+		}
+		line := indent + m.typeTag(path) + highlight(text, ranges, style, sgr(m.theme.Match))
+		if tag != "" {
+			line += paint(m.theme.Dim, tag)
 		}
 		sb.WriteString(fmt.Sprintf("%s %s\n", cursor, line))
 	}
 
+	if m.preview && m.cursor < len(m.matches) {
+		sb.WriteString(renderPreview(m.previews.get(m.matchPath(m.cursor)), m.width, m.theme.Dim))
+	}
+
+	footer := ""
 	if len(m.matches) > 0 {
-		sb.WriteString(fmt.Sprintf("\n  [Showing %d-%d of %d]\n",
-			m.windowStart+1, end, len(m.matches)))
+		footer = m.expandFooter(start, end) + "  "
+	}
+	order := m.sortOrder
+	if m.recent {
+		order = indexer.SortModTime
+	}
+	footer += sgr(m.theme.Dim) + fmt.Sprintf("sorted by %s \u00b7 %d files", order, len(m.allFiles))
+	if m.hidden {
+		footer += " \u00b7 hidden included"
+	}
+	if !m.indexedAt.IsZero() {
+		footer += " \u00b7 indexed " + formatAge(time.Since(m.indexedAt))
+	}
+	sb.WriteString(fmt.Sprintf("\n  %s%s\n", footer, sgrReset))
+	if m.notice != "" {
+		sb.WriteString(fmt.Sprintf("  %s\n", paint(m.theme.Error, m.notice)))
 	}
 
 	return sb.String()
 }
 
-// ---------------------------------------------
-// INDEXING & FS LOGIC
-// ---------------------------------------------
+// defaultFooter is the footer template used unless configured otherwise,
+// and groupedFooter the one it stands for while grouped by directory.
+const (
+	defaultFooter = "[Showing {start}-{end} of {total}]"
+	groupedFooter = "[{total} matches in {dirs} directories]"
+)
 
-func getIndexFilePath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("cannot find home directory: %w", err)
+// expandFooter fills in the footer template for the result rows from
+// start up to end, cut to the terminal's width.
+func (m model) expandFooter(start, end int) string {
+	tmpl := m.footer
+	if tmpl == defaultFooter && m.grouped {
+		tmpl = groupedFooter
+	}
+	total := fmt.Sprint(len(m.matches))
+	if m.matchTotal > len(m.matches) {
+		total += "+"
+	}
+	modes, order := []string{m.mode.String()}, m.sortOrder
+	if m.recent {
+		modes[0], order = "recent", indexer.SortModTime
 	}
-	// Cross-platform path join (e.g. /home/user/.index or C:\Users\Name\.index)
-	return filepath.Join(home, ".index"), nil
+	if m.matchCase() {
+		modes = append(modes, "case-sensitive")
+	}
+	if m.nameOnly {
+		modes = append(modes, "name only")
+	}
+	selected := ""
+	if m.cursor < len(m.matches) {
+		selected = m.matchPath(m.cursor)
+	}
+	text := strings.NewReplacer(
+		"{start}", fmt.Sprint(start+1),
+		"{end}", fmt.Sprint(end),
+		"{total}", total,
+		"{dirs}", fmt.Sprint(m.groupCount()),
+		"{mode}", strings.Join(modes, ", "),
+		"{sort}", order.String(),
+		"{files}", fmt.Sprint(len(m.allFiles)),
+		"{selected}", selected,
+	).Replace(tmpl)
+	if m.width > 0 {
+		// Wrapping would break the window math, like an overlong row
+		text = runewidth.Truncate(text, m.width-2, "\u2026")
+	}
+	return text
 }
 
-func buildIndex(savePath string) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("cannot get home directory: %w", err)
+// highlight renders text with style applied to the whole line and the given
+// ranges shown in match style. Every highlight is closed with a reset and
+// the line style re-applied, so escape sequences never nest.
+func highlight(text string, ranges []indexer.Range, style, match string) string {
+	var sb strings.Builder
+	sb.WriteString(style)
+	pos := 0
+	for _, r := range ranges {
+		sb.WriteString(text[pos:r.Start])
+		sb.WriteString(match + text[r.Start:r.End] + sgrReset + style)
+		pos = r.End
 	}
+	sb.WriteString(text[pos:])
+	if style != "" {
+		sb.WriteString(sgrReset)
+	}
+	return sb.String()
+}
 
-	fmt.Println("Indexing home directory...")
-	var files []string
-	start := time.Now()
+// fitPath shortens path to at most width terminal columns by replacing
+// part of its directory with an ellipsis, keeping the start for context
+// and the base name whole when it fits. ranges, byte ranges of path, are
+// returned moved to match the result; any falling in the removed part are
+// dropped.
+func fitPath(path string, ranges []indexer.Range, width int) (string, []indexer.Range) {
+	if runewidth.StringWidth(path) <= width {
+		return path, ranges
+	}
+	const ellipsis = "\u2026"
+	room := max(width-runewidth.StringWidth(ellipsis), 0)
 
-	err = filepath.WalkDir(home, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
+	// Keep the separator before the base name, so the cut is visibly a
+	// directory part
+	tail := max(strings.LastIndexAny(path, `/\`), 0)
+	if tailWidth := runewidth.StringWidth(path[tail:]); tailWidth < room {
+		room -= tailWidth
+	} else {
+		// The base name alone is too long: keep both of its ends
+		tail = suffixWithin(path, room-room/2)
+		room /= 2
+	}
+	head := prefixWithin(path[:tail], room)
+
+	shift := len(ellipsis) - (tail - head)
+	var out []indexer.Range
+	for _, r := range ranges {
+		if r.Start < head {
+			out = append(out, indexer.Range{Start: r.Start, End: min(r.End, head)})
+		}
+		if r.End > tail {
+			out = append(out, indexer.Range{Start: max(r.Start, tail) + shift, End: r.End + shift})
 		}
-		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
-			return filepath.SkipDir
+	}
+	return path[:head] + ellipsis + path[tail:], out
+}
+
+// prefixWithin returns the length in bytes of the longest prefix of s at
+// most width columns wide.
+func prefixWithin(s string, width int) int {
+	used := 0
+	for i, r := range s {
+		if used += runewidth.RuneWidth(r); used > width {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// suffixWithin returns the byte offset of the longest suffix of s at most
+// width columns wide.
+func suffixWithin(s string, width int) int {
+	used, i := 0, len(s)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if used += runewidth.RuneWidth(r); used > width {
+			break
+		}
+		i -= size
+	}
+	return i
+}
+
+// formatAge renders a duration as a short "3h ago" style age.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// ---------------------------------------------
+// OS INTEGRATION
+// ---------------------------------------------
+
+// linuxFileManagers are tried in order when no file manager is configured,
+// each with the arguments that make it select the file rather than just
+// open its directory.
+var linuxFileManagers = []struct {
+	cmd  string
+	args []string
+}{
+	{"nautilus", []string{"--select"}},
+	{"dolphin", []string{"--select"}},
+	{"caja", []string{"--select"}},
+	{"nemo", nil},   // selects a file given as its argument
+	{"thunar", nil}, // likewise
+}
+
+// openFileLocation shows path in a file manager. fileManager is a command
+// template as for runOpenCommand, e.g. "pcmanfm {}"; when empty the
+// platform's file manager is detected and the file is selected in it, with
+// one exception: a Linux system with none of the ways linuxRevealCommands
+// knows to select a file only gets the file's directory opened.
+func openFileLocation(path, fileManager string) error {
+	printf("Revealing: %s\n", path)
+
+	if fileManager != "" {
+		args, err := expandCommand(fileManager, path)
+		if err != nil {
+			return fmt.Errorf("invalid file manager command: %w", err)
 		}
-		// Security: Skip symlinks
-		if d.Type()&os.ModeSymlink != 0 {
+		return startCommand(args[0], args[1:]...)
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		err := startCommand("explorer", "/select,", path)
+		// Explorer exits with status 1 even when it showed the file
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
 			return nil
 		}
-		if !d.IsDir() {
-			files = append(files, path)
+		return err
+	case "linux":
+		var errs []error
+		for _, args := range linuxRevealCommands(path, isCmd) {
+			err := startCommand(args[0], args[1:]...)
+			if err == nil {
+				if args[0] == "xdg-open" {
+					printf("Opened its directory; selecting the file needs a file manager such as nautilus or dolphin.\n")
+				}
+				return nil
+			}
+			errs = append(errs, err)
 		}
-		if len(files)%10000 == 0 {
-			fmt.Printf("\rIndexed %d files...", len(files))
+		if len(errs) == 0 {
+			return errors.New("no file manager found (install xdg-utils or set file_manager)")
 		}
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("walk error: %w", err)
+		return errors.Join(errs...)
+	case "darwin":
+		return startCommand("open", "-R", path)
 	}
+	return fmt.Errorf("no file manager known for %s", runtime.GOOS)
+}
 
-	fmt.Printf("\nFinished! Indexed %d files in %v\n", len(files), time.Since(start))
-	return saveIndex(savePath, files)
+// linuxRevealCommands returns the commands that can show path on Linux,
+// best first, given has to report whether a command is installed:
+//
+//  1. the first of linuxFileManagers installed, which selects the file
+//  2. dbus-send asking whichever file manager implements the freedesktop
+//     FileManager1 interface to select it, which most current ones do
+//  3. xdg-open on the file's directory, which can't select the file
+//
+// Each is tried in turn until one starts.
+func linuxRevealCommands(path string, has func(string) bool) [][]string {
+	var cmds [][]string
+	for _, fm := range linuxFileManagers {
+		if has(fm.cmd) {
+			cmds = append(cmds, slices.Concat([]string{fm.cmd}, fm.args, []string{path}))
+			break
+		}
+	}
+	if has("dbus-send") {
+		// dbus-send splits arrays at commas
+		uri := strings.ReplaceAll((&url.URL{Scheme: "file", Path: path}).String(), ",", "%2C")
+		cmds = append(cmds, []string{
+			"dbus-send", "--session", "--print-reply", "--dest=org.freedesktop.FileManager1",
+			"/org/freedesktop/FileManager1", "org.freedesktop.FileManager1.ShowItems",
+			"array:string:" + uri, "string:",
+		})
+	}
+	if has("xdg-open") {
+		cmds = append(cmds, []string{"xdg-open", filepath.Dir(path)})
+	}
+	return cmds
 }
 
-func saveIndex(path string, files []string) error {
-	// Security: 0600 = Read/Write by owner only
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+// clipboardMsg delivers the clipboard's text for pasting, "" if there was
+// none or it couldn't be read.
+type clipboardMsg struct {
+	text string
+}
+
+// pasteClipboard reads the clipboard in the background, since the tools
+// doing it can be slow to answer. Line breaks become spaces, keeping the
+// query a single line.
+func pasteClipboard() tea.Msg {
+	text, err := readClipboard()
 	if err != nil {
-		return fmt.Errorf("cannot create index file: %w", err)
+		logger.Debug("cannot read clipboard", "err", err)
+		return clipboardMsg{}
 	}
-	defer f.Close()
+	text = strings.Join(strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }), " ")
+	return clipboardMsg{text: sanitizeInput([]rune(strings.TrimSpace(text)))}
+}
 
-	enc := gob.NewEncoder(f)
-	return enc.Encode(files)
+// copiedMsg reports that copyMatches put count of the total matches on
+// the clipboard, or why it couldn't.
+type copiedMsg struct {
+	count, total int
+	err          error
 }
 
-func loadIndex(path string) ([]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open index file: %w", err)
+// copyMatches copies the paths of all matches kept, one per line, to the
+// clipboard in the background.
+func (m *model) copyMatches() tea.Cmd {
+	if len(m.matches) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	for i := range m.matches {
+		sb.WriteString(m.matchPath(i))
+		sb.WriteByte('\n')
+	}
+	text, count, total := sb.String(), len(m.matches), max(m.matchTotal, len(m.matches))
+	return func() tea.Msg {
+		return copiedMsg{count: count, total: total, err: writeClipboard(text)}
 	}
-	defer f.Close()
+}
 
-	var files []string
-	dec := gob.NewDecoder(f)
-	if err := dec.Decode(&files); err != nil {
-		return nil, fmt.Errorf("invalid index: %w", err)
+// clipboardTimeout bounds using the clipboard, as xclip can hang waiting
+// for an unresponsive owner of the selection.
+const clipboardTimeout = 2 * time.Second
+
+// clipboardTools returns the commands that print the clipboard or, with
+// write set, replace it with their input, in order of preference.
+func clipboardTools(write bool) [][]string {
+	switch runtime.GOOS {
+	case "windows":
+		if write {
+			return [][]string{{"powershell", "-NoProfile", "-Command", "$input | Set-Clipboard"}}
+		}
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+	case "darwin":
+		if write {
+			return [][]string{{"pbcopy"}}
+		}
+		return [][]string{{"pbpaste"}}
+	}
+	var tools [][]string
+	if write {
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			tools = append(tools, []string{"wl-copy"})
+		}
+		return append(tools, []string{"xclip", "-selection", "clipboard", "-in"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, []string{"wl-paste", "--no-newline"})
 	}
-	return files, nil
+	return append(tools, []string{"xclip", "-selection", "clipboard", "-out"}, []string{"xsel", "--clipboard", "--output"})
 }
 
-func openFileLocation(path string) {
-	fmt.Printf("Revealing: %s\n", path)
+// readClipboard returns the text on the system clipboard, using the first
+// tool available on the platform.
+func readClipboard() (string, error) {
+	for _, args := range clipboardTools(false) {
+		if !isCmd(args[0]) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		logger.Debug("reading clipboard", "cmd", args)
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", args[0], err)
+		}
+		return string(out), nil
+	}
+	return "", errors.New("no clipboard tool found")
+}
+
+// writeClipboard puts text on the system clipboard, using the first tool
+// available on the platform.
+func writeClipboard(text string) error {
+	for _, args := range clipboardTools(true) {
+		if !isCmd(args[0]) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		logger.Debug("writing clipboard", "cmd", args)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		return nil
+	}
+	return errors.New("no clipboard tool found")
+}
+
+// hasDisplay reports whether a graphical session is available. Only Unix
+// systems other than macOS can lack one, when neither an X11 nor a Wayland
+// display is set.
+func hasDisplay() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// canReveal reports whether openFileLocation finds a file manager to run.
+func canReveal(fileManager string) bool {
+	if fields := strings.Fields(fileManager); len(fields) > 0 {
+		return isCmd(fields[0])
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	case "linux":
+		return len(linuxRevealCommands("", isCmd)) > 0
+	}
+	return false
+}
+
+// openFile opens path itself with the platform's default application.
+func openFile(path string) error {
+	printf("Opening: %s\n", path)
 
 	switch runtime.GOOS {
 	case "windows":
-		_ = exec.Command("explorer", "/select,", path).Start()
+		// The empty argument is the window title expected by start
+		return startCommand("cmd", "/c", "start", "", path)
 	case "linux":
-		switch {
-		case isCmd("nautilus"):
-			_ = exec.Command("nautilus", "--select", path).Start()
-		case isCmd("dolphin"):
-			_ = exec.Command("dolphin", "--select", path).Start()
-		default:
-			_ = exec.Command("xdg-open", filepath.Dir(path)).Start()
-		}
+		return startCommand("xdg-open", path)
 	case "darwin":
-		_ = exec.Command("open", "-R", path).Start()
+		return startCommand("open", path)
+	}
+	return fmt.Errorf("no default application known for %s", runtime.GOOS)
+}
+
+// launchTimeout is how long a launched application is watched for an
+// early failure before it is left running on its own.
+const launchTimeout = time.Second
+
+// startCommand launches a GUI application. Launchers such as xdg-open exit
+// once they have handed off, so it waits up to launchTimeout to catch
+// them failing; a command still running by then, like a file manager
+// window, is assumed to have worked.
+func startCommand(name string, args ...string) error {
+	logger.Debug("starting command", "cmd", append([]string{name}, args...))
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	case <-time.After(launchTimeout):
+		return nil
+	}
+}
+
+// runOpenCommand runs the command template with every "{}" replaced by
+// path, or with path appended when there is no "{}". The template is split
+// on whitespace before substitution, so paths containing spaces stay a
+// single argument. The command shares the terminal and is waited for.
+func runOpenCommand(template, path string) error {
+	args, err := expandCommand(template, path)
+	if err != nil {
+		return err
+	}
+	logger.Debug("running open command", "cmd", args)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// expandCommand splits template into arguments and substitutes path for
+// every "{}", appending path when the template has none.
+func expandCommand(template, path string) ([]string, error) {
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	substituted := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{}") {
+			args[i] = strings.ReplaceAll(arg, "{}", path)
+			substituted = true
+		}
+	}
+	if !substituted {
+		args = append(args, path)
 	}
+	return args, nil
 }
 
 func isCmd(name string) bool {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"filesearcher/indexer"
+)
+
+// newTestModel returns a model over n files, all of them matching, with
+// the cursor on the first and at most window result rows (0 = fill the
+// terminal).
+func newTestModel(n, window int) model {
+	idx := &indexer.Index{}
+	matches := make([]int, n)
+	for i := range n {
+		idx.Entries = append(idx.Entries, indexer.FileEntry{Path: fmt.Sprintf("/files/%03d.txt", i)})
+		matches[i] = i
+	}
+	m := initialModel(idx, uiOptions{WindowSize: window})
+	m.matches, m.matchTotal = matches, n
+	return m
+}
+
+// checkWindow fails t unless the cursor is on a match inside the window
+// and the window doesn't run past the end of the list.
+func checkWindow(t *testing.T, m model) {
+	t.Helper()
+	if len(m.matches) > 0 && (m.cursor < 0 || m.cursor >= len(m.matches)) {
+		t.Fatalf("cursor %d outside %d matches", m.cursor, len(m.matches))
+	}
+	if m.cursor < m.windowStart || m.cursor >= m.windowStart+m.windowSize {
+		t.Fatalf("cursor %d outside window %d+%d", m.cursor, m.windowStart, m.windowSize)
+	}
+	if m.windowStart > max(len(m.matches)-m.windowSize, 0) {
+		t.Fatalf("window start %d past the end of %d matches", m.windowStart, len(m.matches))
+	}
+}
+
+func TestResultsShrinkUnderCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []int
+		cursor  int // expected afterwards
+	}{
+		{"selection gone", []int{0, 1, 2}, 0},
+		{"selection kept", []int{17, 18, 19}, 2},
+		{"no matches", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(20, 5)
+			m.setCursor(19)
+			if m.windowStart != 15 {
+				t.Fatalf("window start %d at the end of the list, want 15", m.windowStart)
+			}
+			next, _ := m.Update(searchResultMsg{gen: m.searchGen, matches: tt.matches, total: len(tt.matches)})
+			m = next.(model)
+			if m.cursor != tt.cursor {
+				t.Errorf("cursor = %d, want %d", m.cursor, tt.cursor)
+			}
+			checkWindow(t, m)
+		})
+	}
+}
+
+func TestResizeKeepsCursorVisible(t *testing.T) {
+	m := newTestModel(100, 0)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	m = next.(model)
+	m.setCursor(30)
+	for _, height := range []int{10, 6, 3, 1, 60, 20} {
+		next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: height})
+		m = next.(model)
+		if m.cursor != 30 {
+			t.Fatalf("resize to %d rows moved the cursor to %d", height, m.cursor)
+		}
+		checkWindow(t, m)
+	}
+}
+
+func TestPastedControlCharacters(t *testing.T) {
+	tests := []struct {
+		name  string
+		runes string
+		query string
+	}{
+		{"newlines", "main.go\nutil.go\r\n", "main.goutil.go"},
+		{"tabs and escapes", "a\tb\x1b[31mc\x00", "ab[31mc"},
+		{"invalid UTF-8", "caf\ufffde", "cafe"},
+		{"only controls", "\n\r\t", ""},
+		{"plain text", "new folder", "new folder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(3, 5)
+			next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(tt.runes), Paste: true})
+			m = next.(model)
+			if m.query != tt.query || m.caret != len(tt.query) {
+				t.Errorf("query %q with caret %d, want %q with caret %d", m.query, m.caret, tt.query, len(tt.query))
+			}
+		})
+	}
+}
+
+func TestLinuxRevealCommands(t *testing.T) {
+	const path = "/home/me/My Docs/a,b.txt"
+	dbus := []string{
+		"dbus-send", "--session", "--print-reply", "--dest=org.freedesktop.FileManager1",
+		"/org/freedesktop/FileManager1", "org.freedesktop.FileManager1.ShowItems",
+		"array:string:file:///home/me/My%20Docs/a%2Cb.txt", "string:",
+	}
+	xdgOpen := []string{"xdg-open", "/home/me/My Docs"}
+	tests := []struct {
+		name      string
+		installed []string
+		want      [][]string
+	}{
+		{"nautilus", []string{"nautilus", "xdg-open"}, [][]string{{"nautilus", "--select", path}, xdgOpen}},
+		{"dolphin", []string{"dolphin"}, [][]string{{"dolphin", "--select", path}}},
+		{"nemo", []string{"nemo", "dbus-send"}, [][]string{{"nemo", path}, dbus}},
+		{"thunar", []string{"thunar", "dbus-send", "xdg-open"}, [][]string{{"thunar", path}, dbus, xdgOpen}},
+		{"first file manager only", []string{"thunar", "dolphin", "nautilus"}, [][]string{{"nautilus", "--select", path}}},
+		{"dbus-send", []string{"dbus-send", "xdg-open"}, [][]string{dbus, xdgOpen}},
+		{"xdg-open fallback", []string{"xdg-open"}, [][]string{xdgOpen}},
+		{"nothing installed", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			has := func(cmd string) bool { return slices.Contains(tt.installed, cmd) }
+			if got := linuxRevealCommands(path, has); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("linuxRevealCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ---------------------------------------------
+// FILE PREVIEW
+// ---------------------------------------------
+
+const (
+	previewLines    = 10       // lines of the selected file shown below the results
+	previewMaxBytes = 16 << 10 // only this much of a file's head is ever read
+	previewCacheMax = 256      // cached previews before the cache is reset
+)
+
+// filePreview is the rendered head of a file. When the file can't be shown
+// as text, placeholder explains why and lines is empty.
+type filePreview struct {
+	lines       []string
+	placeholder string
+}
+
+// previewCache remembers previews by path so moving the cursor back and
+// forth doesn't re-read files. It is shared by pointer between model copies.
+type previewCache struct {
+	entries map[string]filePreview
+}
+
+func newPreviewCache() *previewCache {
+	return &previewCache{entries: make(map[string]filePreview)}
+}
+
+// get returns the preview of path, reading the file on first use.
+func (c *previewCache) get(path string) filePreview {
+	if p, ok := c.entries[path]; ok {
+		return p
+	}
+	// A simple reset keeps memory bounded without tracking recency
+	if len(c.entries) >= previewCacheMax {
+		clear(c.entries)
+	}
+	p := readPreview(path, previewLines)
+	c.entries[path] = p
+	return p
+}
+
+// readPreview reads the first n lines of path, looking no further than
+// previewMaxBytes into the file. Content containing a NUL byte is treated
+// as binary and not shown.
+func readPreview(path string, n int) filePreview {
+	// Opening a FIFO or device could block or have side effects
+	info, err := os.Stat(path)
+	if err != nil {
+		return filePreview{placeholder: previewError(err)}
+	}
+	if !info.Mode().IsRegular() {
+		return filePreview{placeholder: "not a regular file"}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return filePreview{placeholder: previewError(err)}
+	}
+	defer f.Close()
+
+	buf := make([]byte, previewMaxBytes)
+	size, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return filePreview{placeholder: previewError(err)}
+	}
+	buf = buf[:size]
+
+	switch {
+	case size == 0:
+		return filePreview{placeholder: "empty file"}
+	case bytes.IndexByte(buf, 0) >= 0:
+		return filePreview{placeholder: "binary file"}
+	}
+
+	// The final newline of a file read in full doesn't start another line
+	if size < previewMaxBytes {
+		buf = bytes.TrimSuffix(buf, []byte("\n"))
+	}
+	lines := strings.SplitN(string(buf), "\n", n+1)
+	lines = lines[:min(len(lines), n)]
+	for i, line := range lines {
+		lines[i] = sanitizePreviewLine(line)
+	}
+	return filePreview{lines: lines}
+}
+
+func previewError(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Sprintf("cannot read file: %v", err)
+}
+
+// sanitizePreviewLine expands tabs and drops control characters, so file
+// content can't move the cursor or inject escape sequences.
+func sanitizePreviewLine(line string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimRight(line, "\r") {
+		switch {
+		case r == '\t':
+			sb.WriteString("    ")
+		case unicode.IsControl(r) || r == utf8.RuneError:
+			continue
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// renderPreview formats p for the preview pane, truncating lines to width
+// columns when width is known. dim is the SGR code of the rule and
+// placeholders.
+func renderPreview(p filePreview, width int, dim string) string {
+	var sb strings.Builder
+	rule := strings.Repeat("─", max(min(width-4, 40), 10))
+	sb.WriteString(fmt.Sprintf("\n  %s\n", paint(dim, rule)))
+	if p.placeholder != "" {
+		sb.WriteString(fmt.Sprintf("  %s\n", paint(dim, "("+p.placeholder+")")))
+		return sb.String()
+	}
+	for _, line := range p.lines {
+		if width > 4 {
+			line = runewidth.Truncate(line, width-4, "…")
+		}
+		sb.WriteString("  " + line + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ---------------------------------------------
+// SESSION STATE
+// ---------------------------------------------
+
+// maxHistory bounds how many past queries are kept across sessions.
+const maxHistory = 100
+
+// uiState is what the UI remembers between sessions. Unlike Config it is
+// written by the program itself and not meant to be edited.
+type uiState struct {
+	History []string `json:"history"`
+	// LastQuery is the query on screen when the UI last exited.
+	LastQuery string `json:"last_query"`
+	// Width and Height are the terminal size last reported to the UI, so
+	// its first frame can be laid out before the real size arrives.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+func getStatePath() (string, error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// loadState returns the saved state, or an empty one if none can be read.
+// Losing state is harmless, so errors are not reported.
+func loadState() uiState {
+	var st uiState
+	path, err := getStatePath()
+	if err != nil {
+		return st
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st
+	}
+	_ = json.Unmarshal(data, &st)
+	return st
+}
+
+func saveState(st uiState) error {
+	path, err := getStatePath()
+	if err != nil {
+		return err
+	}
+	if len(st.History) > maxHistory {
+		st.History = st.History[len(st.History)-maxHistory:]
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("cannot create state directory: %w", err)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	// Security: 0600 = Read/Write by owner only, queries can be sensitive
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("cannot write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// INDEX STATISTICS
+// ---------------------------------------------
+
+// statsTopExtensions is how many extensions the stats report lists.
+const statsTopExtensions = 10
+
+// extCount is the number of indexed files with one extension.
+type extCount struct {
+	ext   string
+	count int
+}
+
+// indexStats summarizes an index for the stats subcommand.
+type indexStats struct {
+	roots     []string
+	hidden    bool      // hidden directories were indexed
+	onlyExts  []string  // the extensions indexing was limited to
+	since     time.Time // files modified before it were left out
+	maxSize   int64     // files larger were left out; 0 if none were
+	partial   bool      // the build was interrupted
+	files     int
+	withMeta  int // entries carrying size and mtime; legacy indexes have none
+	totalSize int64
+	oldest    indexer.FileEntry
+	newest    indexer.FileEntry
+	exts      []extCount // most common first
+}
+
+func computeStats(idx *indexer.Index) indexStats {
+	st := indexStats{roots: idx.Roots, hidden: idx.IncludeHidden, onlyExts: idx.IncludeExts, since: idx.Since, maxSize: idx.MaxFileSize, partial: idx.Partial, files: len(idx.Entries)}
+	counts := make(map[string]int)
+	for _, e := range idx.Entries {
+		counts[strings.ToLower(filepath.Ext(e.Path))]++
+		if e.ModTime.IsZero() {
+			continue
+		}
+		st.withMeta++
+		st.totalSize += e.Size
+		if st.oldest.ModTime.IsZero() || e.ModTime.Before(st.oldest.ModTime) {
+			st.oldest = e
+		}
+		if e.ModTime.After(st.newest.ModTime) {
+			st.newest = e
+		}
+	}
+
+	for _, ext := range slices.Sorted(maps.Keys(counts)) {
+		st.exts = append(st.exts, extCount{ext: ext, count: counts[ext]})
+	}
+	// Stable, so equal counts stay in alphabetical order
+	slices.SortStableFunc(st.exts, func(a, b extCount) int { return cmp.Compare(b.count, a.count) })
+	return st
+}
+
+// writeStats prints st as aligned plain text.
+func writeStats(w io.Writer, st indexStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Roots:\t%s\n", strings.Join(st.roots, ", "))
+	fmt.Fprintf(tw, "Files:\t%d\n", st.files)
+	if st.partial {
+		fmt.Fprintf(tw, "Complete:\tno, the build was interrupted (run index to finish it)\n")
+	}
+	if st.hidden {
+		fmt.Fprintf(tw, "Hidden:\tincluded\n")
+	}
+	if len(st.onlyExts) > 0 {
+		fmt.Fprintf(tw, "Limited to:\t%s\n", strings.Join(st.onlyExts, " "))
+	}
+	if !st.since.IsZero() {
+		fmt.Fprintf(tw, "Modified since:\t%s\n", st.since.Format(time.DateTime))
+	}
+	if st.maxSize > 0 {
+		fmt.Fprintf(tw, "Size limit:\t%s\n", formatSize(st.maxSize))
+	}
+	if st.withMeta > 0 {
+		size := formatSize(st.totalSize)
+		if st.withMeta < st.files {
+			size += fmt.Sprintf(" (%d files without metadata)", st.files-st.withMeta)
+		}
+		fmt.Fprintf(tw, "Total size:\t%s\n", size)
+		fmt.Fprintf(tw, "Oldest:\t%s  %s\n", st.oldest.ModTime.Format(time.DateTime), st.oldest.Path)
+		fmt.Fprintf(tw, "Newest:\t%s  %s\n", st.newest.ModTime.Format(time.DateTime), st.newest.Path)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(st.exts) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nTop extensions:\n")
+	for _, ec := range st.exts[:min(len(st.exts), statsTopExtensions)] {
+		ext := ec.ext
+		if ext == "" {
+			ext = "(none)"
+		}
+		pct := 100 * float64(ec.count) / float64(st.files)
+		fmt.Fprintf(tw, "  %s\t%d\t%5.1f%%\n", ext, ec.count, pct)
+	}
+	return tw.Flush()
+}
+
+// formatSize renders a byte count with a binary unit, e.g. "1.5 MiB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// dryRunTopDirs is how many top-level directories a dry run lists.
+const dryRunTopDirs = 20
+
+// writeDryRun reports what indexing would store: the file count, how the
+// files spread over each root's top-level directories, and the size the
+// index file would have.
+func writeDryRun(w io.Writer, idx *indexer.Index) error {
+	counts := make(map[string]int)
+	for _, e := range idx.Entries {
+		counts[topLevelDir(idx.Roots, e.Path)]++
+	}
+	dirs := slices.Sorted(maps.Keys(counts))
+	slices.SortStableFunc(dirs, func(a, b string) int { return cmp.Compare(counts[b], counts[a]) })
+
+	var size countingWriter
+	if err := indexer.Encode(&size, idx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Dry run: %d files would be indexed (index file about %s)\n\n", len(idx.Entries), formatSize(size.n))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, dir := range dirs[:min(len(dirs), dryRunTopDirs)] {
+		fmt.Fprintf(tw, "  %s\t%d\n", dir, counts[dir])
+	}
+	if rest := len(dirs) - dryRunTopDirs; rest > 0 {
+		fmt.Fprintf(tw, "  (%d more directories)\t\n", rest)
+	}
+	return tw.Flush()
+}
+
+// topLevelDir returns the directory directly below one of roots that
+// contains path, or the root itself for files stored at the top.
+func topLevelDir(roots []string, path string) string {
+	for _, root := range roots {
+		if !indexer.IsWithin(root, path) {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			break
+		}
+		first, _, nested := strings.Cut(rel, string(filepath.Separator))
+		if !nested {
+			return root
+		}
+		return filepath.Join(root, first)
+	}
+	return filepath.Dir(path)
+}
+
+// countingWriter discards what is written to it, counting the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
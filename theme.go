@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------
+// THEMES
+// ---------------------------------------------
+
+// theme holds the SGR parameters the UI draws with, such as "1;36" for
+// bold cyan, so colors can suit the terminal's background.
+type theme struct {
+	Cursor string `json:"cursor,omitempty"` // the row under the cursor
+	Match  string `json:"match,omitempty"`  // characters matching the query
+	Dim    string `json:"dim,omitempty"`    // the footer and other secondary text
+	Error  string `json:"error,omitempty"`  // errors and notices
+}
+
+// defaultThemeName is the theme used when none is configured.
+const defaultThemeName = "default"
+
+// builtinThemes can be picked by name with the theme config setting.
+var builtinThemes = map[string]theme{
+	defaultThemeName: {Cursor: "1;36", Match: "7", Dim: "2", Error: "31"},
+	// Cyan washes out on light backgrounds, and faint text can vanish
+	"light": {Cursor: "1;34", Match: "7", Dim: "90", Error: "31"},
+	"mono":  {Cursor: "1", Match: "4", Dim: "2", Error: "1"},
+}
+
+// loadTheme returns the built-in theme called name, or the default one
+// when name is empty, with every non-empty field of custom replacing its
+// color.
+func loadTheme(name string, custom theme) (theme, error) {
+	if name == "" {
+		name = defaultThemeName
+	}
+	t, ok := builtinThemes[name]
+	if !ok {
+		return theme{}, fmt.Errorf("unknown theme %q (want %s)", name, strings.Join(slices.Sorted(maps.Keys(builtinThemes)), ", "))
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&t.Cursor, custom.Cursor},
+		{&t.Match, custom.Match},
+		{&t.Dim, custom.Dim},
+		{&t.Error, custom.Error},
+	} {
+		if f.src == "" {
+			continue
+		}
+		// Security: only SGR parameters, so a config can't smuggle other
+		// escape sequences into the terminal
+		if strings.Trim(f.src, "0123456789;") != "" {
+			return theme{}, fmt.Errorf("invalid color %q: want SGR codes such as \"1;36\"", f.src)
+		}
+		*f.dst = f.src
+	}
+	return t, nil
+}
+
+// sgr returns the escape sequence selecting the SGR parameters code.
+func sgr(code string) string {
+	return "\033[" + code + "m"
+}
+
+// paint renders s in code, resetting all attributes afterwards.
+func paint(code, s string) string {
+	return sgr(code) + s + sgrReset
+}
+
+// sgrReset turns every attribute off again.
+const sgrReset = "\033[0m"
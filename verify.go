@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"filesearcher/indexer"
+)
+
+// ---------------------------------------------
+// INDEX VERIFICATION
+// ---------------------------------------------
+
+// verifyReport is what the verify subcommand found in an index file.
+type verifyReport struct {
+	decodeErr  error // why the file couldn't be read; nothing else is set then
+	entries    int
+	present    int
+	missing    []string
+	unreadable []string // paths whose existence couldn't be checked
+	duplicates []string // paths stored more than once, each listed once
+}
+
+// verifyIndex loads the index at path and checks every entry against the
+// file system without changing anything.
+func verifyIndex(path string) verifyReport {
+	idx, err := indexer.Load(path)
+	if err != nil {
+		return verifyReport{decodeErr: err}
+	}
+
+	r := verifyReport{entries: len(idx.Entries)}
+	seen := make(map[string]int, len(idx.Entries))
+	for _, e := range idx.Entries {
+		seen[e.Path]++
+		if seen[e.Path] == 2 {
+			r.duplicates = append(r.duplicates, e.Path)
+		}
+		if seen[e.Path] > 1 {
+			continue
+		}
+		_, err := os.Lstat(e.Path)
+		switch {
+		case err == nil:
+			r.present++
+		case errors.Is(err, fs.ErrNotExist):
+			r.missing = append(r.missing, e.Path)
+		default:
+			r.unreadable = append(r.unreadable, e.Path)
+		}
+	}
+	return r
+}
+
+// ok reports whether the index needs no attention.
+func (r verifyReport) ok() bool {
+	return r.decodeErr == nil && len(r.missing) == 0 && len(r.unreadable) == 0 && len(r.duplicates) == 0
+}
+
+// writeVerify prints r as aligned counts, listing the affected paths too
+// when list is set.
+func writeVerify(w io.Writer, r verifyReport, list bool) error {
+	if r.decodeErr != nil {
+		_, err := fmt.Fprintf(w, "Decoded:  no (%v)\n", r.decodeErr)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Decoded:\tyes\n")
+	fmt.Fprintf(tw, "Entries:\t%d\n", r.entries)
+	fmt.Fprintf(tw, "Present:\t%d\n", r.present)
+	fmt.Fprintf(tw, "Missing:\t%d\n", len(r.missing))
+	if len(r.unreadable) > 0 {
+		fmt.Fprintf(tw, "Unreadable:\t%d\n", len(r.unreadable))
+	}
+	fmt.Fprintf(tw, "Duplicated:\t%d\n", len(r.duplicates))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if !list {
+		return nil
+	}
+	for _, group := range []struct {
+		title string
+		paths []string
+	}{
+		{"Missing", r.missing},
+		{"Unreadable", r.unreadable},
+		{"Duplicated", r.duplicates},
+	} {
+		if len(group.paths) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", group.title)
+		for _, p := range group.paths {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+	return nil
+}